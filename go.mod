@@ -2,7 +2,6 @@ module github.com/SENERGY-Platform/materialized-view
 
 require (
 	github.com/Azure/go-ansiterm v0.0.0-20170929234023-d6e3b3328b78
-	github.com/JumboInteractiveLimited/jsonpath v0.0.0-20180321012328-6fcdcc9066b5
 	github.com/Microsoft/go-winio v0.4.7
 	github.com/Nvveen/Gotty v0.0.0-20120604004816-cd527374f1e5
 	github.com/SmartEnergyPlatform/amqp-wrapper-lib v0.0.0-20181018071408-32e07d9d89bb
@@ -14,6 +13,7 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/docker/go-connections v0.3.0
 	github.com/docker/go-units v0.3.3
+	github.com/ghodss/yaml v1.0.0
 	github.com/mailru/easyjson v0.0.0-20180531095741-9825584555aa
 	github.com/olivere/elastic v6.1.23+incompatible
 	github.com/opencontainers/go-digest v1.0.0-rc1
@@ -24,7 +24,11 @@ require (
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirupsen/logrus v1.0.5
 	github.com/streadway/amqp v0.0.0-20180528204448-e5adc2ada8b8
+	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
+	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415 // indirect
+	github.com/xeipuuv/gojsonschema v1.1.0
 	golang.org/x/crypto v0.0.0-20180617042118-027cca12c2d6
 	golang.org/x/net v0.0.0-20180611182652-db08ff08e862
 	golang.org/x/sys v0.0.0-20180616030259-6c888cc515d3
+	gopkg.in/yaml.v2 v2.2.1
 )
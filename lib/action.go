@@ -19,7 +19,10 @@ package lib
 import (
 	"errors"
 	"log"
+	"math"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 type ActionType string
@@ -28,6 +31,15 @@ const (
 	RemoveTargetAction ActionType = "remove_target"
 	RemoveAction       ActionType = "remove"
 	InsertAction       ActionType = "insert"
+	UpsertAction       ActionType = "upsert"
+	MergeAction        ActionType = "merge"
+	PatchAction        ActionType = "patch"
+	IncrementAction    ActionType = "increment"
+	DecrementAction    ActionType = "decrement"
+	SetMinAction       ActionType = "set_min"
+	SetMaxAction       ActionType = "set_max"
+	RenameAction       ActionType = "rename"
+	CopyAction         ActionType = "copy"
 )
 
 type ScaleType string
@@ -40,27 +52,60 @@ const (
 type Actions []Action
 
 type Action struct {
-	Type   ActionType   `json:"type"`
-	If     IfConditions `json:"if"`
-	Fields []string     `json:"fields"`
-	Scale  ScaleType    `json:"scale"`
+	Type    ActionType   `json:"type"`
+	If      IfConditions `json:"if"`
+	Fields  []string     `json:"fields"`
+	Scale   ScaleType    `json:"scale"`
+	Key     string       `json:"key"`     //identifies list elements in upsert and remove (scale many) actions
+	Feature string       `json:"feature"` //event feature used by patch and numeric actions
+	Value   interface{}  `json:"value"`   //constant used by numeric actions if no feature is set
+	To      []string     `json:"to"`      //destination fields of rename and copy actions; paired with fields
+
+	//bounds lists of insert (scale many) and upsert actions
+	MaxLength int      `json:"max_length"`
+	SortBy    string   `json:"sort_by"`
+	Keep      KeepType `json:"keep"`
 }
 
+type KeepType string
+
+const (
+	KeepNewest KeepType = "newest"
+	KeepOldest KeepType = "oldest"
+)
+
 func (this Actions) Do(target Target, temp map[string]interface{}, perm map[string]interface{}) (result Target, err error) {
 	result = target
-	for _, action := range this {
+	//the snapshot is only renewed after a change, so unchanged documents are copied once
+	var before map[string]interface{}
+	for index, action := range this {
 		if action.If.CheckFeatures(temp) {
-			result.Changed = true
+			if before == nil {
+				before = copyJsonValue(result.Features).(map[string]interface{})
+			}
 			result, err = action.Do(result, temp, perm)
 			if err != nil {
 				log.Println("ERROR: while doing action", err)
 				return
 			}
+			if action.Type == RemoveTargetAction || !equalFeatures(before, result.Features) {
+				result.Changed = true
+				result.Changes = append(result.Changes, TargetChange{Action: index, Type: action.Type})
+				before = nil
+			}
 		}
 	}
 	return
 }
 
+// nil and empty features are equal
+func equalFeatures(a map[string]interface{}, b map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
 func (this Action) Do(target Target, temp map[string]interface{}, perm map[string]interface{}) (Target, error) {
 	var err error
 	switch this.Type {
@@ -69,6 +114,9 @@ func (this Action) Do(target Target, temp map[string]interface{}, perm map[strin
 			switch this.Scale {
 			case ScaleMany:
 				err = target.addToList(field, perm)
+				if err == nil {
+					err = target.boundList(field, this)
+				}
 			case ScaleOne:
 				err = target.setField(field, perm)
 			default:
@@ -79,10 +127,17 @@ func (this Action) Do(target Target, temp map[string]interface{}, perm map[strin
 			}
 		}
 	case RemoveAction:
+		if this.Scale == ScaleMany && this.Key != "" && !this.hasKeyFeature(perm) {
+			return target, nil
+		}
 		for _, field := range this.Fields {
 			switch this.Scale {
 			case ScaleMany:
-				err = target.removeFromList(field, perm)
+				if this.Key != "" {
+					err = target.removeFromListByKey(field, this.Key, perm)
+				} else {
+					err = target.removeFromList(field, perm)
+				}
 			case ScaleOne:
 				err = target.removeField(field, perm)
 			default:
@@ -92,6 +147,86 @@ func (this Action) Do(target Target, temp map[string]interface{}, perm map[strin
 				return target, err
 			}
 		}
+	case UpsertAction:
+		if this.Key == "" {
+			return target, errors.New("missing key in upsert action")
+		}
+		if !this.hasKeyFeature(perm) {
+			return target, nil
+		}
+		for _, field := range this.Fields {
+			err = target.upsertToList(field, this.Key, perm)
+			if err == nil {
+				err = target.boundList(field, this)
+			}
+			if err != nil {
+				return target, err
+			}
+		}
+	case MergeAction:
+		for _, field := range this.Fields {
+			err = target.mergeField(field, perm)
+			if err != nil {
+				return target, err
+			}
+		}
+	case PatchAction:
+		value, ok := Features(temp).Get(this.Feature)
+		if !ok {
+			log.Println("WARNING: missing patch feature " + this.Feature + "; skip action")
+			return target, nil
+		}
+		patch, err := ParseJsonPatch(value)
+		if err != nil {
+			log.Println("WARNING: invalid json patch; skip action", err)
+			return target, nil
+		}
+		patched := make([]interface{}, len(this.Fields))
+		for index, field := range this.Fields {
+			patched[index], err = target.getPatchedField(field, patch)
+			if err != nil {
+				//patches are event data; failed test operations skip the action silently
+				if _, testFailed := err.(JsonPatchTestError); !testFailed {
+					log.Println("WARNING: unable to apply json patch; skip action", field, err)
+				}
+				return target, nil
+			}
+		}
+		for index, field := range this.Fields {
+			err = target.setPatchedField(field, patched[index])
+			if err != nil {
+				return target, err
+			}
+		}
+	case IncrementAction, DecrementAction, SetMinAction, SetMaxAction:
+		operand, ok, err := this.getNumericOperand(temp)
+		if err != nil || !ok {
+			return target, err
+		}
+		for _, field := range this.Fields {
+			if existing, _ := target.getFieldPath(field); existing != nil {
+				if _, isNumber := toNumber(existing).(float64); !isNumber {
+					log.Println("WARNING: field "+field+" is not numeric; skip "+string(this.Type)+" action", target.Id)
+					return target, nil
+				}
+			}
+		}
+		for _, field := range this.Fields {
+			err = target.updateNumber(field, this.Type, operand)
+			if err != nil {
+				return target, err
+			}
+		}
+	case RenameAction, CopyAction:
+		if len(this.To) != len(this.Fields) {
+			return target, errors.New(string(this.Type) + " action needs one to entry for each field")
+		}
+		for index, field := range this.Fields {
+			err = target.moveField(field, this.To[index], this.Type == CopyAction)
+			if err != nil {
+				return target, err
+			}
+		}
 	case RemoveTargetAction:
 		target.Removed = true
 		target.Changed = true
@@ -102,13 +237,15 @@ func (this Action) Do(target Target, temp map[string]interface{}, perm map[strin
 }
 
 func (this *Target) addToList(field string, value interface{}) (err error) {
-	list := this.Features[field]
-	if reflect.TypeOf(list).Kind() == reflect.Slice {
-		this.Features[field], err = InterfaceSliceAppend(list, value)
-	} else {
-		this.Features[field] = []interface{}{value}
+	list, _ := this.getFieldPath(field)
+	if list != nil && reflect.TypeOf(list).Kind() == reflect.Slice {
+		list, err = InterfaceSliceAppend(list, value)
+		if err != nil {
+			return err
+		}
+		return this.setFieldPath(field, list)
 	}
-	return
+	return this.setFieldPath(field, []interface{}{value})
 }
 
 func (this *Target) setField(field string, value map[string]interface{}) (err error) {
@@ -116,25 +253,320 @@ func (this *Target) setField(field string, value map[string]interface{}) (err er
 		for key, elem := range value {
 			this.Features[key] = elem
 		}
-	} else {
-		this.Features[field] = value
+		return
 	}
-	return
+	return this.setFieldPath(field, value)
 }
 
 func (this *Target) removeFromList(field string, value map[string]interface{}) (err error) {
-	list := this.Features[field]
-	if reflect.TypeOf(list) != nil && reflect.TypeOf(list).Kind() == reflect.Slice {
-		this.Features[field], err = InterfaceSliceRemove(list, value)
+	list, _ := this.getFieldPath(field)
+	if list != nil && reflect.TypeOf(list).Kind() == reflect.Slice {
+		list, err = InterfaceSliceRemove(list, value)
+		if err != nil {
+			return err
+		}
+		return this.setFieldPath(field, list)
+	}
+	return this.setFieldPath(field, []interface{}{})
+}
+
+// replaces the first list element with the same key value or appends the value if no element matches
+func (this *Target) upsertToList(field string, key string, value map[string]interface{}) (err error) {
+	keyValue, ok := Features(value).Get(key)
+	if !ok {
+		return errors.New("missing key " + key + " in event features")
+	}
+	list, _ := this.getFieldPath(field)
+	if list == nil || reflect.TypeOf(list).Kind() != reflect.Slice {
+		return this.setFieldPath(field, []interface{}{value})
+	}
+	elements, err := InterfaceSlice(list)
+	if err != nil {
+		return err
+	}
+	result := []interface{}{}
+	found := false
+	for _, element := range elements {
+		if !found && listElementHasKey(element, key, keyValue) {
+			found = true
+			result = append(result, value)
+		} else {
+			result = append(result, element)
+		}
+	}
+	if !found {
+		result = append(result, value)
+	}
+	return this.setFieldPath(field, result)
+}
+
+// removes all list elements with the same key value
+func (this *Target) removeFromListByKey(field string, key string, value map[string]interface{}) (err error) {
+	keyValue, ok := Features(value).Get(key)
+	if !ok {
+		return errors.New("missing key " + key + " in event features")
+	}
+	list, _ := this.getFieldPath(field)
+	if list == nil || reflect.TypeOf(list).Kind() != reflect.Slice {
+		return this.setFieldPath(field, []interface{}{})
+	}
+	elements, err := InterfaceSlice(list)
+	if err != nil {
+		return err
+	}
+	result := []interface{}{}
+	for _, element := range elements {
+		if !listElementHasKey(element, key, keyValue) {
+			result = append(result, element)
+		}
+	}
+	return this.setFieldPath(field, result)
+}
+
+// events without key can not be assigned to a list element; the action is skipped
+func (this Action) hasKeyFeature(perm map[string]interface{}) bool {
+	_, ok := Features(perm).Get(this.Key)
+	if !ok {
+		log.Println("WARNING: missing key "+this.Key+" in event features; skip action", this.Type)
+	}
+	return ok
+}
+
+func listElementHasKey(element interface{}, key string, keyValue interface{}) bool {
+	elementKeyValue, ok := getFeaturePath(element, strings.Split(key, "."))
+	return ok && reflect.DeepEqual(normalizeKeyValue(elementKeyValue), normalizeKeyValue(keyValue))
+}
+
+// numbers of stored documents are float64, numbers of event features may be integers
+func normalizeKeyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+// deep merges the value into the object field; untouched sub fields are preserved
+func (this *Target) mergeField(field string, value map[string]interface{}) (err error) {
+	if field == "" {
+		if this.Features == nil {
+			this.Features = map[string]interface{}{}
+		}
+		mergeObjects(this.Features, value)
+		return
+	}
+	existing, _ := this.getFieldPath(field)
+	object, ok := existing.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	mergeObjects(object, value)
+	return this.setFieldPath(field, object)
+}
+
+func mergeObjects(target map[string]interface{}, source map[string]interface{}) {
+	for key, value := range source {
+		sourceObject, sourceIsObject := value.(map[string]interface{})
+		targetObject, targetIsObject := target[key].(map[string]interface{})
+		if sourceIsObject && targetIsObject {
+			mergeObjects(targetObject, sourceObject)
+		} else {
+			target[key] = copyJsonValue(value)
+		}
+	}
+}
+
+// paths of the patch are relative to the field; the target is not modified
+func (this *Target) getPatchedField(field string, patch JsonPatch) (result interface{}, err error) {
+	if field == "" {
+		result, err = patch.Apply(this.Features)
+		if err != nil {
+			return result, err
+		}
+		if _, ok := result.(map[string]interface{}); !ok {
+			return result, errors.New("json patch result is no object")
+		}
+		return result, nil
+	}
+	existing, _ := this.getFieldPath(field)
+	return patch.Apply(existing)
+}
+
+// value is a result of getPatchedField
+func (this *Target) setPatchedField(field string, value interface{}) (err error) {
+	if field == "" {
+		this.Features = value.(map[string]interface{})
+		return nil
+	}
+	return this.setFieldPath(field, value)
+}
+
+// uses the feature if set, else the value; increment and decrement default to 1
+// ok is false if the event contains no numeric operand
+func (this Action) getNumericOperand(temp map[string]interface{}) (result float64, ok bool, err error) {
+	var operand interface{}
+	if this.Feature != "" {
+		value, found := Features(temp).Get(this.Feature)
+		if !found {
+			log.Println("WARNING: missing feature " + this.Feature + "; skip " + string(this.Type) + " action")
+			return result, false, nil
+		}
+		operand = value
+	} else if this.Value != nil {
+		operand = this.Value
+	} else if this.Type == IncrementAction || this.Type == DecrementAction {
+		return 1, true, nil
 	} else {
-		this.Features[field] = []interface{}{}
+		return result, false, errors.New("missing feature or value for " + string(this.Type) + " action")
 	}
-	return
+	number, ok := toNumber(operand).(float64)
+	if !ok {
+		log.Println("WARNING: non numeric operand; skip "+string(this.Type)+" action", operand)
+		return result, false, nil
+	}
+	return number, true, nil
+}
+
+// reports config mistakes on config load instead of on every event
+func (this Action) Validate() error {
+	switch this.Type {
+	case SetMinAction, SetMaxAction:
+		if this.Feature == "" && this.Value == nil {
+			return errors.New("missing feature or value for " + string(this.Type) + " action")
+		}
+		fallthrough
+	case IncrementAction, DecrementAction:
+		if _, ok := toNumber(this.Value).(float64); this.Feature == "" && this.Value != nil && !ok {
+			return errors.New("non numeric value for " + string(this.Type) + " action")
+		}
+	}
+	return nil
+}
+
+func (this Actions) Validate() error {
+	for _, action := range this {
+		if err := action.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missing fields are handled as 0 by increment/decrement and are set to the operand by set_min/set_max
+func (this *Target) updateNumber(field string, actionType ActionType, operand float64) (err error) {
+	existing, _ := this.getFieldPath(field)
+	if existing == nil {
+		if actionType == DecrementAction {
+			operand = -operand
+		}
+		return this.setFieldPath(field, operand)
+	}
+	current, ok := toNumber(existing).(float64)
+	if !ok {
+		return errors.New("field " + field + " is not numeric")
+	}
+	switch actionType {
+	case IncrementAction:
+		current = current + operand
+	case DecrementAction:
+		current = current - operand
+	case SetMinAction:
+		current = math.Min(current, operand)
+	case SetMaxAction:
+		current = math.Max(current, operand)
+	}
+	return this.setFieldPath(field, current)
+}
+
+// sorts the list ascending by sort_by and keeps the newest (last) or oldest (first) max_length elements
+func (this *Target) boundList(field string, action Action) (err error) {
+	if action.MaxLength == 0 && action.SortBy == "" {
+		return
+	}
+	if action.Keep != "" && action.Keep != KeepNewest && action.Keep != KeepOldest {
+		return errors.New("unknown keep type " + string(action.Keep))
+	}
+	list, _ := this.getFieldPath(field)
+	elements, err := InterfaceSlice(list)
+	if err != nil {
+		return err
+	}
+	if action.SortBy != "" {
+		sortPath := strings.Split(action.SortBy, ".")
+		sort.SliceStable(elements, func(i, j int) bool {
+			a, _ := getFeaturePath(elements[i], sortPath)
+			b, _ := getFeaturePath(elements[j], sortPath)
+			return lessJsonValue(a, b)
+		})
+	}
+	if action.MaxLength > 0 && len(elements) > action.MaxLength {
+		if action.Keep == KeepOldest {
+			elements = elements[:action.MaxLength]
+		} else {
+			elements = elements[len(elements)-action.MaxLength:]
+		}
+	}
+	return this.setFieldPath(field, elements)
+}
+
+// nil < numbers < strings < other values; numbers in strings are compared as strings
+func lessJsonValue(a interface{}, b interface{}) bool {
+	aRank, aNumber := jsonValueRank(a)
+	bRank, bNumber := jsonValueRank(b)
+	if aRank != bRank {
+		return aRank < bRank
+	}
+	switch aRank {
+	case 1:
+		return aNumber < bNumber
+	case 2:
+		return a.(string) < b.(string)
+	}
+	return false
+}
+
+func jsonValueRank(value interface{}) (rank int, number float64) {
+	switch v := value.(type) {
+	case nil:
+		return 0, 0
+	case float64:
+		return 1, v
+	case int64:
+		return 1, float64(v)
+	case int:
+		return 1, float64(v)
+	case string:
+		return 2, 0
+	}
+	return 3, 0
+}
+
+// missing source fields are ignored; existing destination fields are overwritten
+func (this *Target) moveField(from string, to string, keepSource bool) (err error) {
+	if from == "" || to == "" {
+		return errors.New("rename and copy actions need non empty fields")
+	}
+	value, ok := this.getFieldPath(from)
+	if !ok || from == to {
+		return
+	}
+	if keepSource {
+		value = copyJsonValue(value)
+	} else {
+		this.removeFieldPath(from)
+	}
+	return this.setFieldPath(to, value)
 }
 
 func (this *Target) removeField(field string, value map[string]interface{}) (err error) {
 	if field != "" {
-		delete(this.Features, field)
+		this.removeFieldPath(field)
 	} else {
 		for key := range value {
 			delete(this.Features, key)
@@ -142,3 +574,57 @@ func (this *Target) removeField(field string, value map[string]interface{}) (err
 	}
 	return
 }
+
+// field may use '.' to address nested objects (for example 'gw.devices')
+func (this *Target) getFieldPath(field string) (result interface{}, ok bool) {
+	path := strings.Split(field, ".")
+	parent, ok := this.getParentObject(path, false)
+	if !ok {
+		return nil, false
+	}
+	result, ok = parent[path[len(path)-1]]
+	return
+}
+
+// creates missing intermediate objects
+func (this *Target) setFieldPath(field string, value interface{}) (err error) {
+	path := strings.Split(field, ".")
+	parent, ok := this.getParentObject(path, true)
+	if !ok {
+		return errors.New("unable to set field " + field + ": parent is no object")
+	}
+	parent[path[len(path)-1]] = value
+	return
+}
+
+func (this *Target) removeFieldPath(field string) {
+	path := strings.Split(field, ".")
+	if parent, ok := this.getParentObject(path, false); ok {
+		delete(parent, path[len(path)-1])
+	}
+}
+
+func (this *Target) getParentObject(path []string, create bool) (result map[string]interface{}, ok bool) {
+	if this.Features == nil {
+		if !create {
+			return nil, false
+		}
+		this.Features = map[string]interface{}{}
+	}
+	result = this.Features
+	for _, key := range path[:len(path)-1] {
+		sub, exists := result[key]
+		if !exists || sub == nil {
+			if !create {
+				return nil, false
+			}
+			sub = map[string]interface{}{}
+			result[key] = sub
+		}
+		result, ok = sub.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+	}
+	return result, true
+}
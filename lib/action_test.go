@@ -52,12 +52,12 @@ func ExampleActions_many() {
 
 	//output:
 	//<nil>
-	//{map[list:[]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:b]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:c]]] <nil> id true false false test} <nil>
+	//{map[list:[]] <nil> id true false false test [{  0 0 remove}]} <nil>
+	//{map[list:[map[element:a]]] <nil> id true false false test [{  0 1 insert}]} <nil>
+	//{map[list:[map[element:a] map[element:b]]] <nil> id true false false test [{  0 1 insert}]} <nil>
+	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id true false false test [{  0 1 insert}]} <nil>
+	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id false false false test []} <nil>
+	//{map[list:[map[element:a] map[element:c]]] <nil> id true false false test [{  0 0 remove}]} <nil>
 }
 
 func test_helper_print(m map[string]interface{}){
@@ -170,12 +170,12 @@ func ExampleActions_unknown_action() {
 
 	//output:
 	//<nil>
-	//{map[] <nil> id true false false test} unknown action type foo
-	//{map[] <nil> id true false false test} unknown action type foo
-	//{map[] <nil> id true false false test} unknown action type foo
-	//{map[] <nil> id true false false test} unknown action type foo
-	//{map[] <nil> id true false false test} unknown action type foo
-	//{map[] <nil> id true false false test} unknown action type foo
+	//{map[] <nil> id false false false test []} unknown action type foo
+	//{map[] <nil> id false false false test []} unknown action type foo
+	//{map[] <nil> id false false false test []} unknown action type foo
+	//{map[] <nil> id false false false test []} unknown action type foo
+	//{map[] <nil> id false false false test []} unknown action type foo
+	//{map[] <nil> id false false false test []} unknown action type foo
 }
 
 func ExampleActions_unknown_scale() {
@@ -208,12 +208,12 @@ func ExampleActions_unknown_scale() {
 
 	//output:
 	//<nil>
-	//{map[] <nil> id true false false test} unknown scale type foo
-	//{map[] <nil> id true false false test} unknown scale type foo
-	//{map[] <nil> id true false false test} unknown scale type foo
-	//{map[] <nil> id true false false test} unknown scale type foo
-	//{map[] <nil> id true false false test} unknown scale type foo
-	//{map[] <nil> id true false false test} unknown scale type foo
+	//{map[] <nil> id false false false test []} unknown scale type foo
+	//{map[] <nil> id false false false test []} unknown scale type foo
+	//{map[] <nil> id false false false test []} unknown scale type foo
+	//{map[] <nil> id false false false test []} unknown scale type foo
+	//{map[] <nil> id false false false test []} unknown scale type foo
+	//{map[] <nil> id false false false test []} unknown scale type foo
 }
 
 func ExampleActions_remove_target() {
@@ -251,11 +251,374 @@ func ExampleActions_remove_target() {
 
 	//output:
 	//<nil>
-	//{map[list:[]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:b]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:c]]] <nil> id true false false test} <nil>
-	//{map[list:[map[element:a] map[element:c]]] <nil> id true true false test} <nil>
-}
\ No newline at end of file
+	//{map[list:[]] <nil> id true false false test [{  0 0 remove}]} <nil>
+	//{map[list:[map[element:a]]] <nil> id true false false test [{  0 1 insert}]} <nil>
+	//{map[list:[map[element:a] map[element:b]]] <nil> id true false false test [{  0 1 insert}]} <nil>
+	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id true false false test [{  0 1 insert}]} <nil>
+	//{map[list:[map[element:a] map[element:b] map[element:c]]] <nil> id false false false test []} <nil>
+	//{map[list:[map[element:a] map[element:c]]] <nil> id true false false test [{  0 0 remove}]} <nil>
+	//{map[list:[map[element:a] map[element:c]]] <nil> id true true false test [{  0 2 remove_target}]} <nil>
+}
+
+func ExampleActions_nested() {
+	target := Target{Id: "id", Features: map[string]interface{}{"meta": map[string]interface{}{"created": "today"}, "name": "foo"}, Name: "test"}
+
+	actionsStr := `[
+          {
+            "type": "insert",
+            "if": [{"feature": "command", "operation": "==", "value": "PUT"}],
+            "fields": ["gw.devices"],
+            "scale": "many"
+          },
+          {
+            "type": "insert",
+            "if": [{"feature": "command", "operation": "==", "value": "PUT"}],
+            "fields": ["meta.owner"],
+            "scale": "one"
+          },
+          {
+            "type": "remove",
+            "if": [{"feature": "command", "operation": "==", "value": "DELETE"}],
+            "fields": ["gw.devices"],
+            "scale": "many"
+          },
+          {
+            "type": "remove",
+            "if": [{"feature": "command", "operation": "==", "value": "DELETE"}],
+            "fields": ["meta.owner"],
+            "scale": "one"
+          }
+        ]`
+
+	actions := Actions{}
+	err := json.Unmarshal([]byte(actionsStr), &actions)
+	fmt.Println(err)
+
+	target, err = actions.Do(target, map[string]interface{}{"command": "PUT"}, map[string]interface{}{"id": "a"})
+	features, _ := json.Marshal(target.Features)
+	fmt.Println(string(features), err)
+
+	target, err = actions.Do(target, map[string]interface{}{"command": "PUT"}, map[string]interface{}{"id": "b"})
+	features, _ = json.Marshal(target.Features)
+	fmt.Println(string(features), err)
+
+	target, err = actions.Do(target, map[string]interface{}{"command": "DELETE"}, map[string]interface{}{"id": "a"})
+	features, _ = json.Marshal(target.Features)
+	fmt.Println(string(features), err)
+
+	_, err = actions.Do(target, map[string]interface{}{"command": "PUT"}, map[string]interface{}{"id": "c"})
+	fmt.Println(err)
+
+	invalid := Target{Id: "id", Features: map[string]interface{}{"gw": "no object"}, Name: "test"}
+	_, err = actions.Do(invalid, map[string]interface{}{"command": "PUT"}, map[string]interface{}{"id": "c"})
+	fmt.Println(err)
+
+	//output:
+	//<nil>
+	//{"gw":{"devices":[{"id":"a"}]},"meta":{"created":"today","owner":{"id":"a"}},"name":"foo"} <nil>
+	//{"gw":{"devices":[{"id":"a"},{"id":"b"}]},"meta":{"created":"today","owner":{"id":"b"}},"name":"foo"} <nil>
+	//{"gw":{"devices":[{"id":"b"}]},"meta":{"created":"today"},"name":"foo"} <nil>
+	//<nil>
+	//unable to set field gw.devices: parent is no object
+}
+
+func ExampleActions_upsert() {
+	target := Target{Id: "id", Features: map[string]interface{}{}, Name: "test"}
+
+	actionsStr := `[
+          {
+            "type": "upsert",
+            "if": [{"feature": "command", "operation": "==", "value": "PUT"}],
+            "fields": ["devices"],
+            "key": "id"
+          },
+          {
+            "type": "remove",
+            "if": [{"feature": "command", "operation": "==", "value": "DELETE"}],
+            "fields": ["devices"],
+            "scale": "many",
+            "key": "id"
+          }
+        ]`
+
+	actions := Actions{}
+	err := json.Unmarshal([]byte(actionsStr), &actions)
+	fmt.Println(err)
+
+	events := []struct {
+		command string
+		perm    map[string]interface{}
+	}{
+		{"PUT", map[string]interface{}{"id": "a", "name": "a1"}},
+		{"PUT", map[string]interface{}{"id": "b", "name": "b1"}},
+		{"PUT", map[string]interface{}{"id": "a", "name": "a2"}},
+		{"PUT", map[string]interface{}{"id": "a", "name": "a2"}},
+		{"DELETE", map[string]interface{}{"id": "b"}},
+		{"DELETE", map[string]interface{}{"id": "b"}},
+		{"PUT", map[string]interface{}{"name": "no id"}},
+		{"DELETE", map[string]interface{}{"name": "no id"}},
+		{"PUT", map[string]interface{}{"id": float64(1), "name": "n1"}},
+		{"PUT", map[string]interface{}{"id": int64(1), "name": "n2"}},
+		{"DELETE", map[string]interface{}{"id": int64(1)}},
+	}
+	for _, event := range events {
+		target, err = actions.Do(target, map[string]interface{}{"command": event.command}, event.perm)
+		features, _ := json.Marshal(target.Features)
+		fmt.Println(string(features), err)
+	}
+
+	_, err = Actions{{Type: UpsertAction, Fields: []string{"devices"}}}.Do(target, map[string]interface{}{}, map[string]interface{}{"id": "a"})
+	fmt.Println(err)
+
+	//output:
+	//<nil>
+	//{"devices":[{"id":"a","name":"a1"}]} <nil>
+	//{"devices":[{"id":"a","name":"a1"},{"id":"b","name":"b1"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"},{"id":"b","name":"b1"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"},{"id":"b","name":"b1"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"},{"id":1,"name":"n1"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"},{"id":1,"name":"n2"}]} <nil>
+	//{"devices":[{"id":"a","name":"a2"}]} <nil>
+	//missing key in upsert action
+}
+
+func ExampleActions_merge() {
+	target := Target{Id: "id", Features: map[string]interface{}{
+		"device": map[string]interface{}{"name": "foo", "meta": map[string]interface{}{"owner": "u1", "created": "today"}},
+	}, Name: "test"}
+
+	actionsStr := `[
+          {
+            "type": "merge",
+            "if": [{"feature": "command", "operation": "==", "value": "PUT"}],
+            "fields": ["device", "copy"]
+          },
+          {
+            "type": "patch",
+            "if": [{"feature": "command", "operation": "==", "value": "PATCH"}],
+            "fields": ["device"],
+            "feature": "patch"
+          }
+        ]`
+
+	actions := Actions{}
+	err := json.Unmarshal([]byte(actionsStr), &actions)
+	fmt.Println(err)
+
+	target, err = actions.Do(target, map[string]interface{}{"command": "PUT"}, map[string]interface{}{"meta": map[string]interface{}{"owner": "u2"}, "type": "t1"})
+	features, _ := json.Marshal(target.Features)
+	fmt.Println(string(features), err)
+
+	patch := []interface{}{
+		map[string]interface{}{"op": "remove", "path": "/meta/created"},
+		map[string]interface{}{"op": "replace", "path": "/name", "value": "bar"},
+	}
+	target, err = actions.Do(target, map[string]interface{}{"command": "PATCH", "patch": patch}, map[string]interface{}{})
+	features, _ = json.Marshal(target.Features)
+	fmt.Println(string(features), err)
+
+	_, err = actions.Do(target, map[string]interface{}{"command": "PATCH"}, map[string]interface{}{})
+	fmt.Println(err)
+
+	_, err = actions.Do(target, map[string]interface{}{"command": "PATCH", "patch": "no patch"}, map[string]interface{}{})
+	fmt.Println(err)
+
+	//output:
+	//<nil>
+	//{"copy":{"meta":{"owner":"u2"},"type":"t1"},"device":{"meta":{"created":"today","owner":"u2"},"name":"foo","type":"t1"}} <nil>
+	//{"copy":{"meta":{"owner":"u2"},"type":"t1"},"device":{"meta":{"owner":"u2"},"name":"bar","type":"t1"}} <nil>
+	//<nil>
+	//<nil>
+}
+
+func ExampleActions_patch_test() {
+	target := Target{Id: "id", Features: map[string]interface{}{
+		"device": map[string]interface{}{"name": "foo"},
+		"copy":   map[string]interface{}{"name": "bar"},
+	}, Name: "test"}
+
+	actionsStr := `[
+          {
+            "type": "patch",
+            "fields": ["device", "copy"],
+            "feature": "patch"
+          }
+        ]`
+
+	actions := Actions{}
+	err := json.Unmarshal([]byte(actionsStr), &actions)
+	fmt.Println(err)
+
+	patch := []interface{}{
+		map[string]interface{}{"op": "test", "path": "/name", "value": "foo"},
+		map[string]interface{}{"op": "replace", "path": "/name", "value": "batz"},
+	}
+	target, err = actions.Do(target, map[string]interface{}{"patch": patch}, map[string]interface{}{})
+	features, _ := json.Marshal(target.Features)
+	fmt.Println(string(features), target.Changed, err)
+
+	patch = []interface{}{
+		map[string]interface{}{"op": "replace", "path": "/unknown/name", "value": "batz"},
+	}
+	target, err = actions.Do(target, map[string]interface{}{"patch": patch}, map[string]interface{}{})
+	features, _ = json.Marshal(target.Features)
+	fmt.Println(string(features), target.Changed, err)
+
+	//output:
+	//<nil>
+	//{"copy":{"name":"bar"},"device":{"name":"foo"}} false <nil>
+	//{"copy":{"name":"bar"},"device":{"name":"foo"}} false <nil>
+}
+
+func ExampleActions_numeric() {
+	target := Target{Id: "id", Features: map[string]interface{}{"name": "gw"}, Name: "test"}
+
+	actionsStr := `[
+          {
+            "type": "increment",
+            "if": [{"feature": "command", "operation": "==", "value": "CONNECT"}],
+            "fields": ["connected"]
+          },
+          {
+            "type": "decrement",
+            "if": [{"feature": "command", "operation": "==", "value": "DISCONNECT"}],
+            "fields": ["connected"]
+          },
+          {
+            "type": "increment",
+            "fields": ["stats.bytes"],
+            "feature": "bytes"
+          },
+          {
+            "type": "set_max",
+            "fields": ["last_seen"],
+            "feature": "time"
+          },
+          {
+            "type": "set_min",
+            "fields": ["first_seen"],
+            "feature": "time"
+          }
+        ]`
+
+	actions := Actions{}
+	err := json.Unmarshal([]byte(actionsStr), &actions)
+	fmt.Println(err)
+
+	events := []map[string]interface{}{
+		{"command": "CONNECT", "bytes": 10, "time": 200},
+		{"command": "CONNECT", "bytes": "5", "time": 100},
+		{"command": "DISCONNECT", "bytes": 1, "time": 300},
+	}
+	for _, event := range events {
+		target, err = actions.Do(target, event, map[string]interface{}{})
+		features, _ := json.Marshal(target.Features)
+		fmt.Println(string(features), err)
+	}
+
+	//actions without numeric event data are skipped; the others are applied
+	result, err := actions.Do(target, map[string]interface{}{"command": "CONNECT", "time": 400}, map[string]interface{}{})
+	features, _ := json.Marshal(result.Features)
+	fmt.Println(string(features), err)
+	result, err = actions.Do(target, map[string]interface{}{"command": "CONNECT", "bytes": "foo"}, map[string]interface{}{})
+	features, _ = json.Marshal(result.Features)
+	fmt.Println(string(features), err)
+	result, err = Actions{{Type: SetMaxAction, Fields: []string{"last_seen", "name"}, Value: 1000}}.Do(target, map[string]interface{}{}, map[string]interface{}{})
+	features, _ = json.Marshal(result.Features)
+	fmt.Println(string(features), err)
+
+	fmt.Println(actions.Validate())
+	fmt.Println(Actions{{Type: SetMaxAction, Fields: []string{"last_seen"}}}.Validate())
+	fmt.Println(Actions{{Type: IncrementAction, Fields: []string{"connected"}, Value: "foo"}}.Validate())
+
+	//output:
+	//<nil>
+	//{"connected":1,"first_seen":200,"last_seen":200,"name":"gw","stats":{"bytes":10}} <nil>
+	//{"connected":2,"first_seen":100,"last_seen":200,"name":"gw","stats":{"bytes":15}} <nil>
+	//{"connected":1,"first_seen":100,"last_seen":300,"name":"gw","stats":{"bytes":16}} <nil>
+	//{"connected":2,"first_seen":100,"last_seen":400,"name":"gw","stats":{"bytes":16}} <nil>
+	//{"connected":3,"first_seen":100,"last_seen":400,"name":"gw","stats":{"bytes":16}} <nil>
+	//{"connected":3,"first_seen":100,"last_seen":400,"name":"gw","stats":{"bytes":16}} <nil>
+	//<nil>
+	//missing feature or value for set_max action
+	//non numeric value for increment action
+}
+
+func ExampleActions_bounded() {
+	newest := Target{Id: "id", Features: map[string]interface{}{}, Name: "test"}
+	oldest := Target{Id: "id", Features: map[string]interface{}{}, Name: "test"}
+
+	newestActions := Actions{}
+	err := json.Unmarshal([]byte(`[{"type": "insert", "fields": ["history"], "scale": "many", "max_length": 3, "sort_by": "time"}]`), &newestActions)
+	fmt.Println(err)
+	oldestActions := Actions{}
+	err = json.Unmarshal([]byte(`[{"type": "upsert", "fields": ["history"], "key": "state", "max_length": 2, "sort_by": "time", "keep": "oldest"}]`), &oldestActions)
+	fmt.Println(err)
+
+	events := []map[string]interface{}{
+		{"state": "a", "time": 3},
+		{"state": "b", "time": 1},
+		{"state": "c", "time": 4},
+		{"state": "d", "time": 2},
+		{"state": "e", "time": 5},
+	}
+	for _, event := range events {
+		newest, err = newestActions.Do(newest, map[string]interface{}{}, event)
+		oldest, err = oldestActions.Do(oldest, map[string]interface{}{}, event)
+	}
+	features, _ := json.Marshal(newest.Features)
+	fmt.Println(string(features), err)
+	features, _ = json.Marshal(oldest.Features)
+	fmt.Println(string(features), err)
+
+	_, err = Actions{{Type: InsertAction, Scale: ScaleMany, Fields: []string{"history"}, MaxLength: 1, Keep: "foo"}}.Do(newest, map[string]interface{}{}, map[string]interface{}{})
+	fmt.Println(err)
+
+	//output:
+	//<nil>
+	//<nil>
+	//{"history":[{"state":"a","time":3},{"state":"c","time":4},{"state":"e","time":5}]} <nil>
+	//{"history":[{"state":"b","time":1},{"state":"d","time":2}]} <nil>
+	//unknown keep type foo
+}
+
+func ExampleActions_rename() {
+	target := Target{Id: "id", Features: map[string]interface{}{
+		"name":  "foo",
+		"owner": "u1",
+		"gw":    map[string]interface{}{"devices": []interface{}{"d1"}},
+	}, Name: "test"}
+
+	actionsStr := `[
+          {
+            "type": "rename",
+            "fields": ["owner", "gw.devices", "unknown"],
+            "to": ["meta.owner", "devices", "known"]
+          },
+          {
+            "type": "copy",
+            "fields": ["name"],
+            "to": ["meta.name"]
+          }
+        ]`
+
+	actions := Actions{}
+	err := json.Unmarshal([]byte(actionsStr), &actions)
+	fmt.Println(err)
+
+	target, err = actions.Do(target, map[string]interface{}{}, map[string]interface{}{})
+	features, _ := json.Marshal(target.Features)
+	fmt.Println(string(features), err)
+
+	_, err = Actions{{Type: CopyAction, Fields: []string{"name", "devices"}, To: []string{"title"}}}.Do(target, map[string]interface{}{}, map[string]interface{}{})
+	fmt.Println(err)
+
+	//output:
+	//<nil>
+	//{"devices":["d1"],"gw":{},"meta":{"name":"foo","owner":"u1"},"name":"foo"} <nil>
+	//copy action needs one to entry for each field
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"log"
+	"time"
+
+	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/streadway/amqp"
+)
+
+// delay after a rejected delivery; with Qos(1) a requeued message would otherwise be retried in a tight loop
+const amqpRejectBackoff = 3 * time.Second
+
+// consumes complete amqp deliveries, so that headers, routing key etc. can be used as event features
+type AmqpConsumerFunc func(delivery amqp.Delivery) error
+
+// amqp_wrapper_lib.Connection handles connection, reconnects and exchange declaration;
+// AmqpConnection only adds consumers which receive the complete delivery instead of the body
+type AmqpConnection struct {
+	*amqp_wrapper_lib.Connection
+	reconnectTimeout time.Duration
+	msgLogging       bool
+}
+
+func InitAmqpConnection(url string, resources []string, reconnectTimeout int64) (result *AmqpConnection, err error) {
+	wrapped, err := amqp_wrapper_lib.Init(url, resources, reconnectTimeout)
+	if err != nil {
+		return result, err
+	}
+	return &AmqpConnection{Connection: wrapped, reconnectTimeout: time.Duration(reconnectTimeout) * time.Second}, nil
+}
+
+func (this *AmqpConnection) SetMessageLogging(logging bool) {
+	this.msgLogging = logging
+	this.Connection.SetMessageLogging(logging)
+}
+
+// amqp_wrapper_lib.Connection.Publish ignores publishing errors
+func (this *AmqpConnection) Publish(resource string, payload []byte) (err error) {
+	msg := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		ContentType:  "application/json",
+		Body:         payload,
+	}
+	this.UseChannel(func(channel *amqp.Channel) {
+		err = channel.Publish(resource, "", false, false, msg)
+	})
+	return
+}
+
+// deliveries end if the channel is closed; the consumer is restarted on the channel of the reconnected wrapper connection
+func (this *AmqpConnection) Consume(qname string, resource string, worker AmqpConsumerFunc) (err error) {
+	log.Println("init consumer for ", resource)
+	deliveries, err := this.consume(qname, resource)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			this.runworker(qname, deliveries, worker)
+			log.Println("WARNING: consumer stopped; restart", qname)
+			deliveries, err = this.consume(qname, resource)
+			for err != nil {
+				log.Println("ERROR: unable to restart consumer", qname, err)
+				time.Sleep(this.reconnectTimeout)
+				deliveries, err = this.consume(qname, resource)
+			}
+		}
+	}()
+	return nil
+}
+
+func (this *AmqpConnection) consume(qname string, resource string) (deliveries <-chan amqp.Delivery, err error) {
+	this.UseChannel(func(channel *amqp.Channel) {
+		log.Printf("use %s queue to consume %s\n", qname, resource)
+		var q amqp.Queue
+		q, err = channel.QueueDeclare(qname, true, false, false, false, nil)
+		if err != nil {
+			return
+		}
+		err = channel.Qos(1, 0, true)
+		if err != nil {
+			return
+		}
+		err = channel.QueueBind(q.Name, "", resource, false, nil)
+		if err != nil {
+			return
+		}
+		deliveries, err = channel.Consume(q.Name, "", false, false, false, false, nil)
+	})
+	return
+}
+
+func (this *AmqpConnection) runworker(qname string, deliveries <-chan amqp.Delivery, consumerFunc AmqpConsumerFunc) {
+	for msg := range deliveries {
+		if this.msgLogging {
+			log.Println("amqp receive", qname, string(msg.Body))
+		}
+		err := consumerFunc(msg)
+		if this.msgLogging {
+			log.Println("amqp finished consumption", err)
+		}
+		if err != nil {
+			log.Println("error while processing msg; message consumtion will not be committed", err)
+			err = msg.Reject(true)
+			if err != nil {
+				log.Println("ERROR while rejecting msg", err)
+			}
+			time.Sleep(amqpRejectBackoff)
+		} else {
+			err = msg.Ack(false)
+			if err != nil {
+				log.Println("ERROR while acknowledging msg", err)
+			}
+		}
+	}
+}
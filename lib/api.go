@@ -44,6 +44,28 @@ func getRoutes() (router *jwt_http_router.Router) {
 		PubRsa:    Config.JwtPubRsa,
 	})
 
+	router.GET("/subscribe/:target/:endpoint", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		subscription := &Subscription{Target: ps.ByName("target"), Endpoint: ps.ByName("endpoint"), Jwt: jwt, Params: r.URL.Query()}
+		err := ServeSubscription(res, r, subscription)
+		if err != nil {
+			log.Println("ERROR: ", err)
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		}
+	})
+
+	router.GET("/subscribe/:target/:endpoint/:field/:value", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		subscription := &Subscription{Target: ps.ByName("target"), Endpoint: ps.ByName("endpoint"), Jwt: jwt, Params: r.URL.Query(), Field: ps.ByName("field"), Value: ps.ByName("value")}
+		err := ServeSubscription(res, r, subscription)
+		if err != nil {
+			log.Println("ERROR: ", err)
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		}
+	})
+
+	router.GET("/metrics", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		response.To(res).Json(GetMetrics())
+	})
+
 	router.GET("/search/:target/:searchtext/:endpoint", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
 		target := ps.ByName("target")
 		searchtext := ps.ByName("searchtext")
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"log"
+)
+
+type TargetOperation string
+
+const (
+	CreateOperation TargetOperation = "create"
+	UpdateOperation TargetOperation = "update"
+	DeleteOperation TargetOperation = "delete"
+)
+
+// published to the change_topic of a group after a target was saved or removed
+type TargetChangeEvent struct {
+	Target     string                 `json:"target"`
+	Id         string                 `json:"id"`
+	OldVersion *int64                 `json:"old_version"`
+	NewVersion *int64                 `json:"new_version"`
+	Operation  TargetOperation        `json:"operation"`
+	Document   map[string]interface{} `json:"document,omitempty"`
+}
+
+func NewTargetChangeEvent(target Target, oldVersion *int64, projection Projection) (result TargetChangeEvent) {
+	result = TargetChangeEvent{Target: target.Name, Id: target.Id, OldVersion: oldVersion, NewVersion: target.Version}
+	switch {
+	case target.Removed:
+		result.Operation = DeleteOperation
+	case target.New:
+		result.Operation = CreateOperation
+	default:
+		result.Operation = UpdateOperation
+	}
+	if len(projection) > 0 && !target.Removed {
+		result.Document = projection.Use(target.Features)
+	}
+	return
+}
+
+// the target is already persisted; a failed publish is only logged to prevent a second execution of the actions
+// documents created and removed by the same event never existed for consumers
+func publishTargetChange(topic string, projection Projection, target Target, oldVersion *int64) {
+	if target.New && target.Removed {
+		return
+	}
+	err := sendEvent(topic, NewTargetChangeEvent(target, oldVersion, projection))
+	if err != nil {
+		log.Println("ERROR: unable to publish change event", topic, target.Name, target.Id, err)
+	}
+}
+
+// change topics have to be declared on amqp connection initialization
+func getChangeTopics() (result []string) {
+	known := map[string]bool{}
+	for _, groups := range Config.Events {
+		for _, group := range groups {
+			if group.ChangeTopic != "" && !known[group.ChangeTopic] {
+				known[group.ChangeTopic] = true
+				result = append(result, group.ChangeTopic)
+			}
+		}
+	}
+	for _, relation := range Config.Relations {
+		if relation.ChangeTopic != "" && !known[relation.ChangeTopic] {
+			known[relation.ChangeTopic] = true
+			result = append(result, relation.ChangeTopic)
+		}
+	}
+	return
+}
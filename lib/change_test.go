@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func ExampleNewTargetChangeEvent() {
+	oldVersion := int64(1)
+	newVersion := int64(2)
+	features := map[string]interface{}{"name": "foo", "owner": "u1", "secret": "x"}
+
+	events := []TargetChangeEvent{
+		NewTargetChangeEvent(Target{Id: "id", Name: "device", Features: features, Version: &newVersion, New: true}, nil, Projection{"name", "owner"}),
+		NewTargetChangeEvent(Target{Id: "id", Name: "device", Features: features, Version: &newVersion}, &oldVersion, Projection{"*"}),
+		NewTargetChangeEvent(Target{Id: "id", Name: "device", Features: features, Version: &newVersion, Removed: true}, &oldVersion, Projection{"*"}),
+		NewTargetChangeEvent(Target{Id: "id", Name: "device", Features: features, Version: &newVersion}, &oldVersion, nil),
+	}
+	for _, event := range events {
+		out, _ := json.Marshal(event)
+		fmt.Println(string(out))
+	}
+
+	//Output:
+	//{"target":"device","id":"id","old_version":null,"new_version":2,"operation":"create","document":{"name":"foo","owner":"u1"}}
+	//{"target":"device","id":"id","old_version":1,"new_version":2,"operation":"update","document":{"name":"foo","owner":"u1","secret":"x"}}
+	//{"target":"device","id":"id","old_version":1,"new_version":2,"operation":"delete"}
+	//{"target":"device","id":"id","old_version":1,"new_version":2,"operation":"update"}
+}
@@ -18,13 +18,18 @@ package lib
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/ghodss/yaml"
 )
 
 type ConfigStruct struct {
@@ -35,7 +40,8 @@ type ConfigStruct struct {
 	AmqpReconnectTimeout int64  `json:"amqp_reconnect_timeout"`
 	AmqpConsumerName     string `json:"amqp_consumer_name"`
 
-	AmqpLogging string `json:"amqp_logging"`
+	AmqpLogging   string `json:"amqp_logging"`
+	ChangeLogging string `json:"change_logging"`
 
 	ElasticUrl     string                            `json:"elastic_url"`
 	ElasticRetry   int64                             `json:"elastic_retry"`
@@ -45,35 +51,146 @@ type ConfigStruct struct {
 	ForceUser string `json:"force_user"`
 	ForceAuth string `json:"force_auth"`
 
-	Events EventsConfig `json:"events"`
+	Events          EventsConfig           `json:"events"`
+	Topics          map[string]TopicConfig `json:"topics"`
+	EventErrorTopic string                 `json:"event_error_topic"`
 
 	Queries QueriesConfig `json:"queries"`
 
+	Relations []Relation `json:"relations"`
+
+	Tombstones map[string]TombstoneConfig `json:"tombstones"` //targets with soft delete
+
 	DbInitOnly string `json:"db_init_only"`
 }
 
+// optional settings of a consumed topic
+type TopicConfig struct {
+	Schema     interface{} `json:"schema"`      //json schema every event of the topic has to match
+	ErrorTopic string      `json:"error_topic"` //receives rejected events; overwrites event_error_topic
+
+	EventIdFeature *Feature `json:"event_id_feature"` //events with already processed ids are acknowledged without handling
+	EventIdTtl     string   `json:"event_id_ttl"`     //duration processed ids are remembered; default 24h
+}
+
 type ConfigType *ConfigStruct
 
 var Config ConfigType
 
 func LoadConfig(location string) error {
-	file, error := os.Open(location)
+	file, error := ioutil.ReadFile(location)
 	if error != nil {
 		log.Println("error on config load: ", error)
 		return error
 	}
-	decoder := json.NewDecoder(file)
 	configuration := ConfigStruct{}
-	error = decoder.Decode(&configuration)
+	error = DecodeConfig(file, filepath.Ext(location), &configuration)
+	if error != nil {
+		log.Println("invalid config: ", error)
+		return error
+	}
+	envReport, error := HandleEnvironmentVars(&configuration)
+	if error != nil {
+		log.Println("invalid environment variable: ", error)
+		return error
+	}
+	if len(envReport) > 0 {
+		log.Println("config values from environment: ", envReport)
+	}
+	error = configuration.Events.CompileFeatures()
+	if error != nil {
+		log.Println("invalid feature path: ", error)
+		return error
+	}
+	error = configuration.Events.ValidateActions()
+	if error != nil {
+		log.Println("invalid action: ", error)
+		return error
+	}
+	error = validateEventIdTtls(configuration.Topics)
+	if error != nil {
+		log.Println("invalid topic config: ", error)
+		return error
+	}
+	error = compileEventIdFeatures(configuration.Topics)
 	if error != nil {
-		log.Println("invalid config json: ", error)
+		log.Println("invalid topic config: ", error)
+		return error
+	}
+	error = validateRelations(configuration.Relations)
+	if error != nil {
+		log.Println("invalid relation: ", error)
+		return error
+	}
+	error = validateVersionFields(configuration.Events)
+	if error != nil {
+		log.Println("invalid version config: ", error)
+		return error
+	}
+	error = validateTombstones(configuration)
+	if error != nil {
+		log.Println("invalid tombstone config: ", error)
 		return error
 	}
-	HandleEnvironmentVars(&configuration)
 	Config = &configuration
 	return nil
 }
 
+// decodes yaml (.yaml, .yml), json with comments (.jsonc) or plain json (every other extension) into the config struct
+// yaml is converted to json first, so the same json tags apply to all formats
+func DecodeConfig(content []byte, extension string, config ConfigType) (err error) {
+	switch strings.ToLower(extension) {
+	case ".yaml", ".yml":
+		content, err = yaml.YAMLToJSON(content)
+		if err != nil {
+			return err
+		}
+	case ".jsonc":
+		content = stripJsonComments(content)
+	}
+	return json.Unmarshal(content, config)
+}
+
+// removes // line comments and /* block comments */ outside of json strings
+func stripJsonComments(content []byte) (result []byte) {
+	inString := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			result = append(result, c)
+			if c == '\\' && i+1 < len(content) {
+				i++
+				result = append(result, content[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+		}
+		if c == '/' && i+1 < len(content) && content[i+1] == '/' {
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			if i < len(content) {
+				result = append(result, '\n')
+			}
+			continue
+		}
+		if c == '/' && i+1 < len(content) && content[i+1] == '*' {
+			i += 2
+			for i+1 < len(content) && !(content[i] == '*' && content[i+1] == '/') {
+				i++
+			}
+			i++
+			continue
+		}
+		result = append(result, c)
+	}
+	return
+}
+
 var camel = regexp.MustCompile("(^[^A-Z]*|[A-Z]*)([A-Z][^A-Z]+|$)")
 
 func fieldNameToEnvName(s string) string {
@@ -90,29 +207,199 @@ func fieldNameToEnvName(s string) string {
 }
 
 // preparations for docker
-func HandleEnvironmentVars(config ConfigType) {
+// top level fields are read from env vars named after the field (ElasticUrl -> ELASTIC_URL)
+// maps, structs and lists of non string values expect json as env value (EVENTS='{"deviceinstance": [...]}')
+// nested values can be addressed by appending map keys, list indexes or field names separated by '__' (EVENTS__deviceinstance__0__TARGET=device)
+// returns a report which maps the config path of each overwritten value to the used env var
+func HandleEnvironmentVars(config ConfigType) (report map[string]string, err error) {
+	report = map[string]string{}
+	environment := os.Environ()
+	sort.Strings(environment)
 	configValue := reflect.Indirect(reflect.ValueOf(config))
 	configType := configValue.Type()
 	for index := 0; index < configType.NumField(); index++ {
-		fieldName := configType.Field(index).Name
-		envName := fieldNameToEnvName(fieldName)
+		field := configType.Field(index)
+		envName := fieldNameToEnvName(field.Name)
 		envValue := os.Getenv(envName)
 		if envValue != "" {
-			fmt.Println("use environment variable: ", envName, " = ", envValue)
-			if configValue.FieldByName(fieldName).Kind() == reflect.Int64 {
-				i, _ := strconv.ParseInt(envValue, 10, 64)
-				configValue.FieldByName(fieldName).SetInt(i)
+			log.Println("use environment variable: ", envName, " = ", envValue)
+			value, err := parseEnvValue(field.Type, envValue)
+			if err != nil {
+				return report, errors.New("invalid environment variable " + envName + ": " + err.Error())
+			}
+			configValue.Field(index).Set(value)
+			report[jsonFieldName(field)] = envName
+		}
+		for _, env := range environment {
+			parts := strings.SplitN(env, "=", 2)
+			if len(parts) != 2 || parts[1] == "" || !strings.HasPrefix(parts[0], envName+envPathSeparator) {
+				continue
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.String {
-				configValue.FieldByName(fieldName).SetString(envValue)
+			path := strings.Split(strings.TrimPrefix(parts[0], envName+envPathSeparator), envPathSeparator)
+			log.Println("use environment variable: ", parts[0], " = ", parts[1])
+			value, err := setEnvPath(configValue.Field(index), path, parts[1])
+			if err != nil {
+				return report, errors.New("invalid environment variable " + parts[0] + ": " + err.Error())
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
-				val := []string{}
-				for _, element := range strings.Split(envValue, ",") {
-					val = append(val, strings.TrimSpace(element))
-				}
-				configValue.FieldByName(fieldName).Set(reflect.ValueOf(val))
+			configValue.Field(index).Set(value)
+			report[jsonFieldName(field)+"."+strings.Join(path, ".")] = parts[0]
+		}
+	}
+	return
+}
+
+const envPathSeparator = "__"
+
+// returns a copy of current where the value at path is replaced by the parsed envValue
+// missing map entries, list elements (only at index == len) and nil maps/pointers are created
+func setEnvPath(current reflect.Value, path []string, envValue string) (result reflect.Value, err error) {
+	if len(path) == 0 {
+		return parseEnvValue(current.Type(), envValue)
+	}
+	result = reflect.New(current.Type()).Elem()
+	result.Set(current)
+	switch result.Kind() {
+	case reflect.Interface:
+		if result.IsNil() {
+			result.Set(reflect.ValueOf(map[string]interface{}{}))
+		}
+		inner, err := setEnvPath(result.Elem(), path, envValue)
+		if err != nil {
+			return result, err
+		}
+		result.Set(inner)
+	case reflect.Ptr:
+		if result.IsNil() {
+			result.Set(reflect.New(result.Type().Elem()))
+		}
+		inner, err := setEnvPath(result.Elem(), path, envValue)
+		if err != nil {
+			return result, err
+		}
+		result.Elem().Set(inner)
+	case reflect.Map:
+		if result.Type().Key().Kind() != reflect.String {
+			return result, errors.New("unable to use non string map key " + path[0])
+		}
+		if result.IsNil() {
+			result.Set(reflect.MakeMap(result.Type()))
+		}
+		key := envMapKey(result, path[0])
+		sub := result.MapIndex(key)
+		if !sub.IsValid() {
+			sub = reflect.Zero(result.Type().Elem())
+		}
+		inner, err := setEnvPath(sub, path[1:], envValue)
+		if err != nil {
+			return result, err
+		}
+		result.SetMapIndex(key, inner)
+	case reflect.Slice:
+		index, err := strconv.Atoi(path[0])
+		if err != nil || index < 0 || index > result.Len() {
+			return result, errors.New("invalid list index " + path[0])
+		}
+		if index == result.Len() {
+			result.Set(reflect.Append(result, reflect.Zero(result.Type().Elem())))
+		}
+		inner, err := setEnvPath(result.Index(index), path[1:], envValue)
+		if err != nil {
+			return result, err
+		}
+		result.Index(index).Set(inner)
+	case reflect.Struct:
+		index, ok := envStructField(result.Type(), path[0])
+		if !ok {
+			return result, errors.New("unknown field " + path[0])
+		}
+		inner, err := setEnvPath(result.Field(index), path[1:], envValue)
+		if err != nil {
+			return result, err
+		}
+		result.Field(index).Set(inner)
+	default:
+		return result, errors.New("unable to traverse " + result.Kind().String() + " with " + path[0])
+	}
+	return result, nil
+}
+
+// prefers exact key matches; env var names are often upper case, so existing keys are also matched case insensitive
+func envMapKey(m reflect.Value, name string) reflect.Value {
+	key := reflect.ValueOf(name).Convert(m.Type().Key())
+	if m.MapIndex(key).IsValid() {
+		return key
+	}
+	for _, existing := range m.MapKeys() {
+		if strings.EqualFold(existing.String(), name) {
+			return existing
+		}
+	}
+	return key
+}
+
+func envStructField(structType reflect.Type, name string) (index int, ok bool) {
+	for index = 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+		if strings.EqualFold(jsonFieldName(field), name) || fieldNameToEnvName(field.Name) == strings.ToUpper(name) {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func parseEnvValue(valueType reflect.Type, envValue string) (result reflect.Value, err error) {
+	result = reflect.New(valueType).Elem()
+	switch valueType.Kind() {
+	case reflect.String:
+		result.SetString(envValue)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(envValue)
+		if err != nil {
+			return result, err
+		}
+		result.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(envValue, 10, valueType.Bits())
+		if err != nil {
+			return result, err
+		}
+		result.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(envValue, 10, valueType.Bits())
+		if err != nil {
+			return result, err
+		}
+		result.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(envValue, valueType.Bits())
+		if err != nil {
+			return result, err
+		}
+		result.SetFloat(f)
+	case reflect.Interface:
+		var val interface{}
+		if json.Unmarshal([]byte(envValue), &val) != nil {
+			val = envValue
+		}
+		if val != nil {
+			result.Set(reflect.ValueOf(val))
+		}
+	default:
+		if valueType.Kind() == reflect.Slice && valueType.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(envValue), "[") {
+			for _, element := range strings.Split(envValue, ",") {
+				result.Set(reflect.Append(result, reflect.ValueOf(strings.TrimSpace(element)).Convert(valueType.Elem())))
 			}
+			return result, nil
 		}
+		err = json.Unmarshal([]byte(envValue), result.Addr().Interface())
 	}
+	return result, err
 }
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+func ExampleDecodeConfig() {
+	yamlConfig := `
+server_port: "8080"
+# comment for ops
+elastic_retry: 3
+events:
+  deviceinstance:
+    - type: root
+      target: device
+      id_feature: id
+      features:
+        - {name: id, path: "$.id+"}
+`
+	jsoncConfig := `{
+	"server_port": "8080", // comment for ops
+	/* multi
+	   line */
+	"elastic_url": "http://elastic//not-a-comment",
+	"elastic_retry": 3
+}`
+
+	config := ConfigStruct{}
+	fmt.Println(DecodeConfig([]byte(yamlConfig), ".yaml", &config))
+	fmt.Println(config.ServerPort, config.ElasticRetry, config.Events["deviceinstance"][0].Target, config.Events["deviceinstance"][0].Features[0].Path)
+
+	config = ConfigStruct{}
+	fmt.Println(DecodeConfig([]byte(jsoncConfig), ".jsonc", &config))
+	fmt.Println(config.ServerPort, config.ElasticUrl, config.ElasticRetry)
+
+	config = ConfigStruct{}
+	fmt.Println(DecodeConfig([]byte(jsoncConfig), ".json", &config) != nil)
+
+	//output:
+	//<nil>
+	//8080 3 device $.id+
+	//<nil>
+	//8080 http://elastic//not-a-comment 3
+	//true
+}
+
+func ExampleHandleEnvironmentVars() {
+	config := ConfigStruct{}
+	err := DecodeConfig([]byte(`{
+		"elastic_retry": 3,
+		"elastic_mapping": {"device": {"name": {"type": "text"}}},
+		"events": {"deviceinstance": [{"type": "root", "target": "device", "features": [{"name": "id", "path": "$.id+"}]}]}
+	}`), ".json", &config)
+	fmt.Println(err)
+
+	os.Setenv("ELASTIC_RETRY", "5")
+	os.Setenv("ELASTIC_MAPPING__device__name__type", "keyword")
+	os.Setenv("EVENTS__deviceinstance__0__TARGET", "device_v2")
+	os.Setenv("EVENTS__deviceinstance__0__features__1", `{"name": "name", "path": "$.name+"}`)
+	os.Setenv("QUERIES", `{"device": {"list": {"selection": {"all": true}, "projection": ["*"]}}}`)
+	defer func() {
+		for _, env := range []string{"ELASTIC_RETRY", "ELASTIC_MAPPING__device__name__type", "EVENTS__deviceinstance__0__TARGET", "EVENTS__deviceinstance__0__features__1", "QUERIES"} {
+			os.Unsetenv(env)
+		}
+	}()
+
+	report, err := HandleEnvironmentVars(&config)
+	fmt.Println(err)
+	fmt.Println(config.ElasticRetry, config.ElasticMapping["device"]["name"])
+	fmt.Println(config.Events["deviceinstance"][0].Target, len(config.Events["deviceinstance"][0].Features), config.Events["deviceinstance"][0].Features[1].Name, config.Events["deviceinstance"][0].Features[1].Path)
+	fmt.Println(config.Queries["device"]["list"].Selection.All, config.Queries["device"]["list"].Projection)
+
+	paths := []string{}
+	for path, env := range report {
+		paths = append(paths, path+"="+env)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+
+	os.Setenv("EVENTS__deviceinstance__2__TARGET", "foo")
+	_, err = HandleEnvironmentVars(&config)
+	os.Unsetenv("EVENTS__deviceinstance__2__TARGET")
+	fmt.Println(err)
+
+	os.Setenv("ELASTIC_RETRY", "foo")
+	_, err = HandleEnvironmentVars(&config)
+	fmt.Println(err)
+
+	//output:
+	//<nil>
+	//<nil>
+	//5 map[type:keyword]
+	//device_v2 2 name $.name+
+	//true [*]
+	//elastic_mapping.device.name.type=ELASTIC_MAPPING__device__name__type
+	//elastic_retry=ELASTIC_RETRY
+	//events.deviceinstance.0.TARGET=EVENTS__deviceinstance__0__TARGET
+	//events.deviceinstance.0.features.1=EVENTS__deviceinstance__0__features__1
+	//queries=QUERIES
+	//invalid environment variable EVENTS__deviceinstance__2__TARGET: invalid list index 2
+	//invalid environment variable ELASTIC_RETRY: strconv.ParseInt: parsing "foo": invalid syntax
+}
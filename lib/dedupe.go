@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/olivere/elastic"
+)
+
+// elasticsearch index remembering processed event ids of topics with event_id_feature
+const DedupeIndex = "matview_dedupe"
+
+const defaultEventIdTtl = 24 * time.Hour
+
+type ProcessedEvent struct {
+	Topic       string `json:"topic"`
+	Group       int    `json:"group"`
+	EventId     string `json:"event_id"`
+	ProcessedAt int64  `json:"processed_at"` //unix time in ms
+}
+
+// returns "" if the topic has no event_id_feature or the event contains no id
+func getEventId(topicConfig TopicConfig, event *Event) (string, error) {
+	if topicConfig.EventIdFeature == nil {
+		return "", nil
+	}
+	feature := *topicConfig.EventIdFeature
+	feature.Name = "event_id"
+	feature.Temp = true
+	temp, _, err := EventToFeatures([]Feature{feature}, event)
+	if err != nil {
+		return "", err
+	}
+	if temp["event_id"] == nil {
+		log.Println("WARNING: event without id; deduplication not possible", event.Meta.Topic)
+		return "", nil
+	}
+	return fmt.Sprint(temp["event_id"]), nil
+}
+
+// ids are remembered per action-group, so that a redelivery after a partial failure only calls the failed and following groups
+func getProcessedEventDocId(topic string, group int, eventId string) string {
+	return topic + ":" + strconv.Itoa(group) + ":" + eventId
+}
+
+func IsEventProcessed(topic string, group int, eventId string) (bool, error) {
+	return GetClient().Exists().Index(DedupeIndex).Type(ElasticResourceType).Id(getProcessedEventDocId(topic, group, eventId)).Do(context.Background())
+}
+
+// a conflict means a concurrent consumer has handled the same event
+func SetEventProcessed(topic string, group int, eventId string) error {
+	_, err := GetClient().Index().Index(DedupeIndex).Type(ElasticResourceType).Id(getProcessedEventDocId(topic, group, eventId)).OpType("create").BodyJson(ProcessedEvent{
+		Topic:       topic,
+		Group:       group,
+		EventId:     eventId,
+		ProcessedAt: time.Now().UnixNano() / int64(time.Millisecond),
+	}).Do(context.Background())
+	if elastic.IsConflict(err) {
+		log.Println("WARNING: event handled concurrently", topic, group, eventId)
+		return nil
+	}
+	return err
+}
+
+func getEventIdTtl(topicConfig TopicConfig) (time.Duration, error) {
+	if topicConfig.EventIdTtl == "" {
+		return defaultEventIdTtl, nil
+	}
+	ttl, err := time.ParseDuration(topicConfig.EventIdTtl)
+	if err == nil && ttl <= 0 {
+		err = errors.New("event_id_ttl has to be positive")
+	}
+	return ttl, err
+}
+
+func validateEventIdTtls(topics map[string]TopicConfig) error {
+	for topic, topicConfig := range topics {
+		if _, err := getEventIdTtl(topicConfig); err != nil {
+			return errors.New("invalid event_id_ttl of topic " + topic + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// invalid paths are reported on config load instead of failing every event of the topic
+func compileEventIdFeatures(topics map[string]TopicConfig) error {
+	for topic, topicConfig := range topics {
+		if topicConfig.EventIdFeature == nil {
+			continue
+		}
+		features := []Feature{*topicConfig.EventIdFeature}
+		if err := CompileFeatures(features); err != nil {
+			return errors.New("invalid event_id_feature of topic " + topic + ": " + err.Error())
+		}
+		*topicConfig.EventIdFeature = features[0]
+	}
+	return nil
+}
+
+func usesEventIds() bool {
+	for _, topicConfig := range Config.Topics {
+		if topicConfig.EventIdFeature != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func createDedupeIndex(client *elastic.Client, ctx context.Context) error {
+	exists, err := client.IndexExists(DedupeIndex).Do(ctx)
+	if err != nil || exists {
+		return err
+	}
+	mapping := map[string]interface{}{"mappings": map[string]interface{}{ElasticResourceType: map[string]interface{}{"properties": map[string]interface{}{
+		"topic":        map[string]string{"type": "keyword"},
+		"group":        map[string]string{"type": "integer"},
+		"event_id":     map[string]string{"type": "keyword"},
+		"processed_at": map[string]string{"type": "long"},
+	}}}}
+	createIndex, err := client.CreateIndex(DedupeIndex).BodyJson(mapping).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !createIndex.Acknowledged {
+		return errors.New("index not acknowledged")
+	}
+	return nil
+}
+
+// periodically deletes processed event ids older than the configured ttl
+func StartEventIdCleanup() {
+	for topic, topicConfig := range Config.Topics {
+		if topicConfig.EventIdFeature == nil {
+			continue
+		}
+		ttl, err := getEventIdTtl(topicConfig)
+		if err != nil {
+			log.Println("ERROR: invalid event id ttl", topic, topicConfig.EventIdTtl, err)
+			continue
+		}
+		go runEventIdCleanup(topic, ttl)
+	}
+}
+
+func runEventIdCleanup(topic string, ttl time.Duration) {
+	interval := ttl
+	if interval > maxTombstoneCleanupInterval {
+		interval = maxTombstoneCleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := CleanupProcessedEvents(topic, ttl)
+		if err != nil {
+			log.Println("ERROR: event id cleanup", topic, err)
+		}
+	}
+}
+
+func CleanupProcessedEvents(topic string, ttl time.Duration) error {
+	deadline := time.Now().Add(-ttl).UnixNano() / int64(time.Millisecond)
+	query := elastic.NewBoolQuery().Filter(elastic.NewTermQuery("topic", topic), elastic.NewRangeQuery("processed_at").Lt(deadline))
+	_, err := GetClient().DeleteByQuery(DedupeIndex).Type(ElasticResourceType).Query(query).ProceedOnVersionConflict().Do(context.Background())
+	return err
+}
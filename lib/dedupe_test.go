@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func printEventId(eventId string, err error) {
+	fmt.Printf("%q %v\n", eventId, err)
+}
+
+func ExampleTopicConfig_eventId() {
+	topics := map[string]TopicConfig{}
+	err := json.Unmarshal([]byte(`{
+		"deviceinstance": {"event_id_feature": {"path": "$.event_id+"}, "event_id_ttl": "1h"},
+		"devicetype": {"event_id_feature": {"path": "$meta.message_id+"}},
+		"other": {}
+	}`), &topics)
+	fmt.Println(err)
+
+	printEventId(getEventId(topics["deviceinstance"], NewEvent([]byte(`{"event_id": "e1", "id": "d1"}`), EventMeta{Topic: "deviceinstance"})))
+	printEventId(getEventId(topics["deviceinstance"], NewEvent([]byte(`{"event_id": 42}`), EventMeta{Topic: "deviceinstance"})))
+	printEventId(getEventId(topics["deviceinstance"], NewEvent([]byte(`{"id": "d1"}`), EventMeta{Topic: "deviceinstance"})))
+	printEventId(getEventId(topics["devicetype"], NewEvent([]byte(`{"id": "dt1"}`), EventMeta{Topic: "devicetype", MessageId: "m1"})))
+	printEventId(getEventId(topics["other"], NewEvent([]byte(`{"event_id": "e1"}`), EventMeta{Topic: "other"})))
+	fmt.Println("doc id:", getProcessedEventDocId("deviceinstance", 1, "e1"))
+
+	fmt.Println(validateEventIdTtls(topics))
+	fmt.Println(validateEventIdTtls(map[string]TopicConfig{"deviceinstance": {EventIdTtl: "1 day"}}) != nil)
+	fmt.Println(validateEventIdTtls(map[string]TopicConfig{"deviceinstance": {EventIdTtl: "-1h"}}))
+
+	fmt.Println(compileEventIdFeatures(topics))
+	fmt.Println(compileEventIdFeatures(map[string]TopicConfig{"deviceinstance": {EventIdFeature: &Feature{Path: "$.event_id[+"}}}) != nil)
+
+	//Output:
+	//<nil>
+	//"e1" <nil>
+	//"42" <nil>
+	//"" <nil>
+	//"m1" <nil>
+	//"" <nil>
+	//doc id: deviceinstance:1:e1
+	//<nil>
+	//true
+	//invalid event_id_ttl of topic deviceinstance: event_id_ttl has to be positive
+	//<nil>
+	//true
+}
@@ -53,6 +53,12 @@ func createClient() (result *elastic.Client) {
 			panic(err)
 		}
 	}
+	if usesEventIds() {
+		err = createDedupeIndex(result, ctx)
+		if err != nil {
+			panic(err)
+		}
+	}
 	return
 }
 
@@ -76,6 +82,8 @@ func createIndex(kind string, client *elastic.Client, ctx context.Context) (err
 			return errors.New("index not acknowledged")
 		}
 		_, err = client.Alias().Add(kind+"_v1", kind).Do(ctx)
+	} else if useTombstones(kind) {
+		err = putTombstoneMapping(kind, client, ctx)
 	}
 	return
 }
@@ -113,6 +121,9 @@ func createMapping(kind string) (result map[string]map[string]map[string]map[str
 		mapping = map[string]interface{}{}
 	}
 	mapping["feature_search"] = map[string]string{"type": "text", "analyzer": "autocomplete", "search_analyzer": "standard"}
+	if useTombstones(kind) {
+		mapping[TombstoneField] = getTombstoneMapping()
+	}
 	result = map[string]map[string]map[string]map[string]interface{}{
 		"mappings": {
 			ElasticResourceType: {
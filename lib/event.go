@@ -20,20 +20,20 @@ import (
 	"encoding/json"
 	"log"
 
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/streadway/amqp"
 )
 
-var conn *amqp_wrapper_lib.Connection
+var conn *AmqpConnection
 
 func InitEventHandling() (err error) {
-	conn, err = amqp_wrapper_lib.Init(Config.AmqpUrl, Config.Events.GetTopicList(), Config.AmqpReconnectTimeout)
+	conn, err = InitAmqpConnection(Config.AmqpUrl, getAmqpResources(), Config.AmqpReconnectTimeout)
 	if err != nil {
 		log.Fatal("ERROR: while initializing amqp connection ", err, "CONFIG: ", Config.AmqpUrl, Config.Events.GetTopicList(), Config.AmqpReconnectTimeout)
 		return
 	}
 	conn.SetMessageLogging(Config.AmqpLogging == "true")
 	for topic, groupes := range Config.Events {
-		handler, err := createHandler(groupes)
+		handler, err := createHandler(topic, Config.Topics[topic], groupes)
 		if err != nil {
 			log.Fatal("ERROR: while creating topic handler", topic, err)
 			return err
@@ -47,20 +47,78 @@ func InitEventHandling() (err error) {
 	return
 }
 
-func createHandler(groupes []EventActionGroup) (handler amqp_wrapper_lib.ConsumerFunc, err error) {
-	groupHandlers := []amqp_wrapper_lib.ConsumerFunc{}
-	for _, group := range groupes {
+// consumed topics and topics used by sendEvent
+func getAmqpResources() (result []string) {
+	result = Config.Events.GetTopicList()
+	known := map[string]bool{}
+	for _, topic := range result {
+		known[topic] = true
+	}
+	for _, topic := range append(getErrorTopics(), getChangeTopics()...) {
+		if !known[topic] {
+			known[topic] = true
+			result = append(result, topic)
+		}
+	}
+	return
+}
+
+func createHandler(topic string, topicConfig TopicConfig, groupes []EventActionGroup) (handler AmqpConsumerFunc, err error) {
+	schema, err := CompileEventSchema(topicConfig.Schema)
+	if err != nil {
+		return handler, err
+	}
+	groupHandlers := []GroupHandler{}
+	for index, group := range groupes {
+		group.index = index
 		groupHandler, err := CreateGroupHandler(group)
 		if err != nil {
 			return handler, err
 		}
 		groupHandlers = append(groupHandlers, groupHandler)
 	}
-	return func(delivery []byte) error {
-		for _, handler := range groupHandlers {
-			if err := handler(delivery); err != nil {
+	return func(delivery amqp.Delivery) error {
+		eventsReceived.Add(topic, 1)
+		event := NewEvent(delivery.Body, DeliveryToEventMeta(topic, delivery))
+		validationErrors, err := ValidateEvent(schema, event)
+		if err != nil {
+			eventsFailed.Add(topic, 1)
+			return err
+		}
+		if len(validationErrors) > 0 {
+			return rejectEvent(event, "", validationErrors)
+		}
+		eventId, err := getEventId(topicConfig, event)
+		if err != nil {
+			eventsFailed.Add(topic, 1)
+			return err
+		}
+		duplicate := false
+		for group, handler := range groupHandlers {
+			if eventId != "" {
+				processed, err := IsEventProcessed(topic, group, eventId)
+				if err != nil {
+					eventsFailed.Add(topic, 1)
+					return err
+				}
+				if processed {
+					duplicate = true
+					continue
+				}
+			}
+			if err := handler(event); err != nil {
+				eventsFailed.Add(topic, 1)
 				return err
 			}
+			if eventId != "" {
+				//the group is already handled; a redelivery would apply its actions again
+				if err := SetEventProcessed(topic, group, eventId); err != nil {
+					log.Println("ERROR: unable to remember processed event", topic, group, eventId, err)
+				}
+			}
+		}
+		if duplicate {
+			eventsDuplicate.Add(topic, 1)
 		}
 		return nil
 	}, err
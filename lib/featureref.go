@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// computes the value of a default_ref
+type FeatureRef func(meta EventMeta) interface{}
+
+// computes the value of a default_ref which starts with a registered prefix; name is the remaining part of the ref
+type FeatureRefPrefix func(name string, meta EventMeta) interface{}
+
+var featureRefMux sync.RWMutex
+
+var featureRef = map[string]FeatureRef{
+	"time.epoch_millis": func(meta EventMeta) interface{} {
+		return time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+	},
+	"time.epoch_second": func(meta EventMeta) interface{} {
+		return time.Now().Unix()
+	},
+	"time.rfc3339": func(meta EventMeta) interface{} {
+		return time.Now().Format(time.RFC3339)
+	},
+	"uuid.v4": func(meta EventMeta) interface{} {
+		return uuid.NewV4().String()
+	},
+	"event.topic": func(meta EventMeta) interface{} {
+		if meta.Topic == "" {
+			return nil
+		}
+		return meta.Topic
+	},
+	"event.received_at": func(meta EventMeta) interface{} {
+		if meta.ReceivedAt.IsZero() {
+			return nil
+		}
+		return meta.ReceivedAt.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+	},
+	"service.instance": func(meta EventMeta) interface{} {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil
+		}
+		return hostname
+	},
+}
+
+var featureRefPrefix = map[string]FeatureRefPrefix{
+	"env.": func(name string, meta EventMeta) interface{} {
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		return val
+	},
+}
+
+// adds or replaces a default_ref; allows embedding code to provide custom refs
+func RegisterFeatureRef(name string, ref FeatureRef) {
+	featureRefMux.Lock()
+	defer featureRefMux.Unlock()
+	featureRef[name] = ref
+}
+
+// adds or replaces a default_ref prefix (like "env.")
+func RegisterFeatureRefPrefix(prefix string, ref FeatureRefPrefix) {
+	featureRefMux.Lock()
+	defer featureRefMux.Unlock()
+	featureRefPrefix[prefix] = ref
+}
+
+// refs are called outside of the lock, so they may register other refs; the longest matching prefix wins
+func UseFeatureRef(name string, meta EventMeta) (result interface{}, ok bool) {
+	ref, prefixRef, prefix, ok := getFeatureRef(name)
+	if !ok {
+		return nil, false
+	}
+	if ref != nil {
+		return ref(meta), true
+	}
+	return prefixRef(strings.TrimPrefix(name, prefix), meta), true
+}
+
+func getFeatureRef(name string) (ref FeatureRef, prefixRef FeatureRefPrefix, prefix string, ok bool) {
+	featureRefMux.RLock()
+	defer featureRefMux.RUnlock()
+	if ref, ok := featureRef[name]; ok {
+		return ref, nil, "", true
+	}
+	for candidate, candidateRef := range featureRefPrefix {
+		if strings.HasPrefix(name, candidate) && (prefixRef == nil || len(candidate) > len(prefix)) {
+			prefix = candidate
+			prefixRef = candidateRef
+		}
+	}
+	return nil, prefixRef, prefix, prefixRef != nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bouk/monkey"
+)
+
+func ExampleRegisterFeatureRef() {
+	wayback := time.Date(1974, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patch := monkey.Patch(time.Now, func() time.Time { return wayback })
+	defer patch.Unpatch()
+
+	os.Setenv("MATVIEW_TEST_REF", "env_value")
+	defer os.Unsetenv("MATVIEW_TEST_REF")
+
+	RegisterFeatureRef("test.custom", func(meta EventMeta) interface{} {
+		return "custom_" + meta.Topic
+	})
+	RegisterFeatureRefPrefix("test.prefix.", func(name string, meta EventMeta) interface{} {
+		return "prefix_" + name
+	})
+	RegisterFeatureRefPrefix("test.", func(name string, meta EventMeta) interface{} {
+		return "short_" + name
+	})
+
+	meta := EventMeta{Topic: "deviceinstance", ReceivedAt: time.Date(2018, time.October, 18, 12, 0, 0, 0, time.UTC)}
+	msg := []byte(`{}`)
+	for _, ref := range []string{"time.rfc3339", "event.topic", "event.received_at", "env.MATVIEW_TEST_REF", "env.MATVIEW_TEST_UNKNOWN", "test.custom", "test.prefix.foo", "test.other", "unknown"} {
+		fmt.Println(MsgWithMetaToFeatures([]Feature{{Name: "a", DefaultRef: ref}}, msg, meta))
+	}
+	fmt.Println(MsgToFeatures([]Feature{{Name: "a", DefaultRef: "event.topic"}}, msg))
+
+	_, perm, _ := MsgWithMetaToFeatures([]Feature{{Name: "a", DefaultRef: "uuid.v4"}, {Name: "b", DefaultRef: "service.instance"}}, msg, meta)
+	hostname, _ := os.Hostname()
+	fmt.Println(len(perm["a"].(string)), perm["b"] == hostname)
+
+	//output:
+	//map[a:1974-05-19T01:02:03Z] map[a:1974-05-19T01:02:03Z] <nil>
+	//map[a:deviceinstance] map[a:deviceinstance] <nil>
+	//map[a:1539864000000] map[a:1539864000000] <nil>
+	//map[a:env_value] map[a:env_value] <nil>
+	//map[a:<nil>] map[a:<nil>] <nil>
+	//map[a:custom_deviceinstance] map[a:custom_deviceinstance] <nil>
+	//map[a:prefix_foo] map[a:prefix_foo] <nil>
+	//map[a:short_other] map[a:short_other] <nil>
+	//map[a:<nil>] map[a:<nil>] <nil>
+	//map[a:<nil>] map[a:<nil>] <nil>
+	//36 true
+}
@@ -18,46 +18,143 @@ package lib
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
 )
 
 type Features map[string]interface{}
 
 type Feature struct {
-	Name       string      `json:"name"`
-	Path       string      `json:"path"`
-	Temp       bool        `json:"temp"`
-	Omitempty  bool        `json:"omitempty"`
-	Default    interface{} `json:"default"`
-	DefaultRef string      `json:"default_ref"`
+	Name       string            `json:"name"`
+	Path       string            `json:"path"`
+	Temp       bool              `json:"temp"`
+	Omitempty  bool              `json:"omitempty"`
+	Default    interface{}       `json:"default"`
+	DefaultRef string            `json:"default_ref"`
+	Template   string            `json:"template"`
+	Transforms FeatureTransforms `json:"transforms"`
+
+	compiledPath *JsonPath
+}
+
+// compiles the paths of the features; called on config load so that events only evaluate the compiled paths
+func CompileFeatures(features []Feature) (err error) {
+	for index := range features {
+		for _, transform := range features[index].Transforms {
+			if err = transform.Validate(); err != nil {
+				return errors.New("feature " + features[index].Name + ": " + err.Error())
+			}
+		}
+		if features[index].Path == "" {
+			continue
+		}
+		features[index].compiledPath, err = GetJsonPath(jsonPathSource(features[index].Path))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-var featureRef = map[string]func() interface{}{
-	"time.epoch_millis": func() interface{} {
-		return time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
-	},
-	"time.epoch_second": func() interface{} {
-		return time.Now().Unix()
-	},
+// features which are not compiled by CompileFeatures use the global path cache
+func (this Feature) jsonPath() (*JsonPath, error) {
+	if this.compiledPath != nil {
+		return this.compiledPath, nil
+	}
+	return GetJsonPath(jsonPathSource(this.Path))
+}
+
+// received event; the body is decoded once and shared by the features of all groups of the topic
+type Event struct {
+	Meta    EventMeta
+	body    []byte
+	doc     interface{}
+	decoded bool
+	metaDoc map[string]interface{}
+}
+
+func NewEvent(body []byte, meta EventMeta) *Event {
+	return &Event{body: body, Meta: meta}
+}
+
+// uses a already decoded document (for example a elasticsearch document) as event body
+func NewDocEvent(doc interface{}, meta EventMeta) *Event {
+	return &Event{doc: doc, decoded: true, Meta: meta}
+}
+
+// decodes the body on first use
+func (this *Event) Doc() (doc interface{}, err error) {
+	if !this.decoded {
+		err = json.Unmarshal(this.body, &this.doc)
+		if err != nil {
+			return nil, err
+		}
+		this.decoded = true
+	}
+	return this.doc, nil
+}
+
+// raw body of the event or the json encoded document if the event was created from a document
+func (this *Event) Body() []byte {
+	if this.body == nil && this.decoded {
+		this.body, _ = json.Marshal(this.doc)
+	}
+	return this.body
+}
+
+func (this *Event) MetaDoc() map[string]interface{} {
+	if this.metaDoc == nil {
+		this.metaDoc = this.Meta.ToMap()
+	}
+	return this.metaDoc
 }
 
 func MsgToFeatures(features []Feature, msg []byte) (temp Features, permanent Features, err error) {
+	return EventToFeatures(features, NewEvent(msg, EventMeta{}))
+}
+
+// meta is used by default_refs that describe the received event (like event.topic)
+func MsgWithMetaToFeatures(features []Feature, msg []byte, meta EventMeta) (temp Features, permanent Features, err error) {
+	return EventToFeatures(features, NewEvent(msg, meta))
+}
+
+func MsgStructToFeatures(features []Feature, msgStruct map[string]interface{}, meta EventMeta) (temp Features, permanent Features, err error) {
+	return EventToFeatures(features, NewDocEvent(msgStruct, meta))
+}
+
+// path results are copied, so that actions can not modify the shared event document
+func EventToFeatures(features []Feature, event *Event) (temp Features, permanent Features, err error) {
 	temp = map[string]interface{}{}
 	permanent = map[string]interface{}{}
 	for _, feature := range features {
 		var pathResult interface{}
-		if feature.Path == "" {
-			pathResult = UseDefault(feature)
-		} else {
-			pathResult, err = UseJsonPath(msg, feature.Path)
+		if feature.Path != "" {
+			path, err := feature.jsonPath()
 			if err != nil {
 				return temp, permanent, err
 			}
-			if pathResult == nil {
-				pathResult = UseDefault(feature)
+			if isMetaPath(feature.Path) {
+				pathResult = copyJsonValue(path.Eval(event.MetaDoc()))
+			} else {
+				doc, err := event.Doc()
+				if err != nil {
+					return temp, permanent, err
+				}
+				pathResult = copyJsonValue(path.Eval(doc))
 			}
+		} else if feature.Template != "" {
+			pathResult = UseTemplate(feature.Template, temp)
+		}
+		pathResult, err = feature.Transforms.Use(pathResult, temp)
+		if err != nil {
+			return temp, permanent, err
+		}
+		if pathResult == nil {
+			pathResult = UseDefault(feature, event.Meta)
 		}
 		if !(feature.Omitempty && isEmpty(pathResult)) {
 			temp[feature.Name] = pathResult
@@ -69,24 +166,58 @@ func MsgToFeatures(features []Feature, msg []byte) (temp Features, permanent Fea
 	return
 }
 
-func MsgStructToFeatures(features []Feature, msgStruct map[string]interface{}) (temp Features, permanent Features, err error) {
-	msg, err := json.Marshal(msgStruct)
-	if err != nil {
-		return temp, permanent, err
+// deep copy of maps and lists
+func copyJsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			result[key] = copyJsonValue(element)
+		}
+		return result
+	case Features:
+		return copyJsonValue(map[string]interface{}(v))
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for index, element := range v {
+			result[index] = copyJsonValue(element)
+		}
+		return result
 	}
-	return MsgToFeatures(features, msg)
+	return value
 }
 
-func UseDefault(feature Feature) interface{} {
+func UseDefault(feature Feature, meta EventMeta) interface{} {
 	if feature.Default != nil {
 		return feature.Default
 	}
-	if ref, ok := featureRef[feature.DefaultRef]; ok {
-		return ref()
+	if feature.DefaultRef != "" {
+		result, _ := UseFeatureRef(feature.DefaultRef, meta)
+		return result
 	}
 	return nil
 }
 
+var templatePlaceholder = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`)
+
+// replaces {{feature}} placeholders with the values of previously extracted features
+// returns nil if a referenced feature is missing or null
+func UseTemplate(template string, features Features) interface{} {
+	missing := false
+	result := templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		val, ok := features.Get(templatePlaceholder.FindStringSubmatch(placeholder)[1])
+		if !ok || val == nil {
+			missing = true
+			return ""
+		}
+		return joinableString(val)
+	})
+	if missing {
+		return nil
+	}
+	return result
+}
+
 func isEmpty(value interface{}) bool {
 	if value == nil {
 		return true
@@ -102,20 +233,85 @@ func isEmpty(value interface{}) bool {
 	return false
 }
 
+// location is a '.' separated path of map keys and list indexes (negative indexes count from the end)
+// '*' visits every element of a list or map; the results are returned as list (nested wildcards are flattened)
 func (this Features) Get(location string) (result interface{}, ok bool) {
-	path := strings.Split(location, ".")
-	result = this
-	for _, key := range path {
-		ok = true
-		if reflect.TypeOf(result).Kind() == reflect.Map && reflect.TypeOf(result).Key().Kind() == reflect.String {
-			sub := reflect.ValueOf(result).MapIndex(reflect.ValueOf(key))
-			if !sub.IsValid() {
-				return nil, false
+	return getFeaturePath(map[string]interface{}(this), strings.Split(location, "."))
+}
+
+func getFeaturePath(value interface{}, path []string) (result interface{}, ok bool) {
+	if len(path) == 0 {
+		return value, true
+	}
+	if value == nil {
+		return nil, false
+	}
+	key, rest := path[0], path[1:]
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.Map:
+		if reflectValue.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		if sub := reflectValue.MapIndex(reflect.ValueOf(key).Convert(reflectValue.Type().Key())); sub.IsValid() {
+			return getFeaturePath(sub.Interface(), rest)
+		}
+		if key != "*" {
+			return nil, false
+		}
+		keys := []string{}
+		for _, mapKey := range reflectValue.MapKeys() {
+			keys = append(keys, mapKey.String())
+		}
+		sort.Strings(keys)
+		elements := []interface{}{}
+		for _, mapKey := range keys {
+			elements = append(elements, reflectValue.MapIndex(reflect.ValueOf(mapKey).Convert(reflectValue.Type().Key())).Interface())
+		}
+		return getWildcardPath(elements, rest), true
+	case reflect.Slice, reflect.Array:
+		if key == "*" {
+			elements := []interface{}{}
+			for i := 0; i < reflectValue.Len(); i++ {
+				elements = append(elements, reflectValue.Index(i).Interface())
 			}
-			result = sub.Interface()
-		} else {
+			return getWildcardPath(elements, rest), true
+		}
+		index, err := strconv.Atoi(key)
+		if err != nil {
 			return nil, false
 		}
+		if index < 0 {
+			index = reflectValue.Len() + index
+		}
+		if index < 0 || index >= reflectValue.Len() {
+			return nil, false
+		}
+		return getFeaturePath(reflectValue.Index(index).Interface(), rest)
+	default:
+		return nil, false
+	}
+}
+
+// elements without the remaining path are skipped
+func getWildcardPath(elements []interface{}, path []string) (result []interface{}) {
+	result = []interface{}{}
+	flattenResult := false
+	for _, key := range path {
+		if key == "*" {
+			flattenResult = true
+		}
+	}
+	for _, element := range elements {
+		sub, ok := getFeaturePath(element, path)
+		if !ok {
+			continue
+		}
+		if list, isList := sub.([]interface{}); isList && flattenResult {
+			result = append(result, list...)
+		} else {
+			result = append(result, sub)
+		}
 	}
 	return
 }
@@ -17,6 +17,7 @@
 package lib
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -223,3 +224,90 @@ func ExampleMsgToFeaturesDefaults() {
 	//map[a:138157323] map[a:138157323] <nil>
 	//map[a:138157323] map[a:138157323] <nil>
 }
+
+func ExampleUseTemplate() {
+	msg := `{"device": {"id": "d1", "name": "foo"}, "service": "s1", "count": 2}`
+	features := []Feature{
+		{Name: "device_id", Path: "$.device.id+", Temp: true},
+		{Name: "device", Path: "$.device+", Temp: true},
+		{Name: "service_id", Path: "$.service+"},
+		{Name: "count", Path: "$.count+"},
+		{Name: "id", Template: "{{device_id}}:{{service_id}}"},
+		{Name: "label", Template: "{{ device.name }} ({{count}})"},
+		{Name: "missing", Template: "{{device_id}}:{{unknown}}", Default: "default"},
+		{Name: "missing_omit", Template: "{{unknown}}", Omitempty: true},
+		{Name: "joined", Template: "{{device_id}}-{{service_id}}", Transforms: FeatureTransforms{{Type: ConcatTransform, Features: []string{"count"}, Separator: "-"}}},
+	}
+	_, perm, err := MsgToFeatures(features, []byte(msg))
+	fmt.Println(err)
+	fmt.Println(perm["label"], perm["id"], perm["missing"], perm["joined"])
+	_, ok := perm["missing_omit"]
+	fmt.Println(ok)
+
+	//output:
+	//<nil>
+	//foo (2) d1:s1 default d1-s1-2
+	//false
+}
+
+func ExampleEventToFeatures() {
+	event := NewEvent([]byte(`{"id": "d1", "device": {"name": "foo", "tags": ["a"]}}`), EventMeta{Topic: "deviceinstance"})
+
+	_, perm1, err := EventToFeatures([]Feature{{Name: "device", Path: "$.device+"}}, event)
+	fmt.Println(perm1, err)
+
+	//actions may modify features without changing the event for other groups
+	perm1["device"].(map[string]interface{})["name"] = "changed"
+
+	_, perm2, err := EventToFeatures([]Feature{{Name: "id", Path: "$.id+"}, {Name: "name", Path: "$.device.name+"}, {Name: "topic", Path: "$meta.topic+"}}, event)
+	fmt.Println(perm2, err)
+
+	_, perm3, err := MsgStructToFeatures([]Feature{{Name: "tag", Path: "$.device.tags[0]"}}, map[string]interface{}{"device": map[string]interface{}{"tags": []interface{}{"b"}}}, EventMeta{})
+	fmt.Println(perm3, err)
+
+	_, _, err = EventToFeatures([]Feature{{Name: "id", Path: "$.id+"}}, NewEvent([]byte(`{invalid`), EventMeta{}))
+	fmt.Println(err != nil)
+
+	_, perm4, err := EventToFeatures([]Feature{{Name: "topic", Path: "$meta.topic+"}}, NewEvent([]byte(`{invalid`), EventMeta{Topic: "foo"}))
+	fmt.Println(perm4, err)
+
+	//output:
+	//map[device:map[name:foo tags:[a]]] <nil>
+	//map[id:d1 name:foo topic:deviceinstance] <nil>
+	//map[tag:b] <nil>
+	//true
+	//map[topic:foo] <nil>
+}
+
+func ExampleFeatures_Get() {
+	features := Features{}
+	json.Unmarshal([]byte(`{
+		"device": {"id": "d1"},
+		"devices": [{"id": "d1"}, {"id": "d2"}, {"name": "no id"}],
+		"services": [{"ids": ["s1", "s2"]}, {"ids": ["s3"]}],
+		"tags": {"b": "tag_b", "a": "tag_a"}
+	}`), &features)
+
+	fmt.Println(features.Get("device.id"))
+	fmt.Println(features.Get("devices.0.id"))
+	fmt.Println(features.Get("devices.-2.id"))
+	fmt.Println(features.Get("devices.5.id"))
+	fmt.Println(features.Get("devices.*.id"))
+	fmt.Println(features.Get("services.*.ids.*"))
+	fmt.Println(features.Get("services.*.ids"))
+	fmt.Println(features.Get("tags.*"))
+	fmt.Println(features.Get("device.id.foo"))
+	fmt.Println(features.Get("unknown.*"))
+
+	//Output:
+	//d1 true
+	//d1 true
+	//d2 true
+	//<nil> false
+	//[d1 d2] true
+	//[s1 s2 s3] true
+	//[[s1 s2] [s3]] true
+	//[tag_a tag_b] true
+	//<nil> false
+	//<nil> false
+}
@@ -17,9 +17,8 @@
 package lib
 
 import (
+	"encoding/json"
 	"log"
-
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
 )
 
 type GroupType string
@@ -38,6 +37,15 @@ type EventActionGroup struct {
 	Features  []Feature         `json:"features"`
 	Actions   Actions           `json:"actions"`
 	Init      []InitActionGroup `json:"init"`
+	Schema    interface{}       `json:"schema"`
+
+	VersionFeature string `json:"version_feature"` //events with a version lower or equal to the version stored in the target are skipped
+	VersionField   string `json:"version_field"`   //target field storing the last applied version; required if version_feature is set
+
+	ChangeTopic      string     `json:"change_topic"`      //receives a TargetChangeEvent for each saved or removed target
+	ChangeProjection Projection `json:"change_projection"` //document fields included in change events
+
+	index int //position in the events of the topic; identifies the group in recorded changes
 }
 
 type InitActionGroup struct {
@@ -49,9 +57,27 @@ type InitActionGroup struct {
 	Actions   Actions         `json:"actions"`
 }
 
-func CreateGroupHandler(group EventActionGroup) (handler amqp_wrapper_lib.ConsumerFunc, err error) {
-	return func(delivery []byte) error {
-		temp, perma, err := MsgToFeatures(group.Features, delivery)
+// handles a received event; the event is shared by all groups of the topic
+type GroupHandler func(event *Event) error
+
+func CreateGroupHandler(group EventActionGroup) (handler GroupHandler, err error) {
+	schema, err := CompileEventSchema(group.Schema)
+	if err != nil {
+		return handler, err
+	}
+	return func(event *Event) error {
+		validationErrors, err := ValidateEvent(schema, event)
+		if err != nil {
+			return err
+		}
+		if len(validationErrors) > 0 {
+			//a redelivery would repeat the other groups of the topic
+			if err := rejectEvent(event, group.Target, validationErrors); err != nil {
+				log.Println("ERROR: unable to publish rejected event", event.Meta.Topic, group.Target, err)
+			}
+			return nil
+		}
+		temp, perma, err := EventToFeatures(group.Features, event)
 		if err != nil {
 			return err
 		}
@@ -60,9 +86,9 @@ func CreateGroupHandler(group EventActionGroup) (handler amqp_wrapper_lib.Consum
 		}
 		switch group.Type {
 		case RootGroupType:
-			return handleRoot(group, temp, perma)
+			return handleRoot(group, temp, perma, event.Meta)
 		case ChildGroupType:
-			return handleChild(group, temp, perma)
+			return handleChild(group, temp, perma, event.Meta)
 		default:
 			log.Println("WARNING: unknown group type, will not be processed", group.Type)
 		}
@@ -70,29 +96,41 @@ func CreateGroupHandler(group EventActionGroup) (handler amqp_wrapper_lib.Consum
 	}, nil
 }
 
-func handleChild(group EventActionGroup, temp map[string]interface{}, perm map[string]interface{}) error {
+func handleChild(group EventActionGroup, temp map[string]interface{}, perm map[string]interface{}, meta EventMeta) error {
 	targets, err := GetTargetsWhere(group.Target, group.Where, temp)
 	if err != nil {
 		return err
 	}
 	for _, target := range targets {
+		eventVersion, ok := group.CheckVersion(target, temp)
+		if !ok {
+			continue
+		}
+		previous := getSubscriptionSnapshot(target)
 		result, err := group.Actions.Do(target, temp, perm)
 		if err != nil {
 			return err
 		}
-		if result.Removed {
-			err = result.Remove()
-		} else if result.Changed {
-			err = result.Save()
+		result.setChangeSource(meta.Topic, group.Target, group.index)
+		err = group.SetVersion(&result, eventVersion)
+		if err != nil {
+			return err
 		}
+		err = persistTarget(result, group, previous)
 		if err != nil {
 			return err
 		}
+		if result.Removed {
+			err = cleanupRelations(result)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func handleRoot(group EventActionGroup, temp map[string]interface{}, perm map[string]interface{}) error {
+func handleRoot(group EventActionGroup, temp map[string]interface{}, perm map[string]interface{}, meta EventMeta) error {
 	target, validRequest, err := GetTargetById(group.Target, group.IdFeature, temp)
 	if err != nil {
 		if validRequest {
@@ -102,25 +140,71 @@ func handleRoot(group EventActionGroup, temp map[string]interface{}, perm map[st
 			return nil
 		}
 	}
+	eventVersion, ok := group.CheckVersion(target, temp)
+	if !ok {
+		return nil
+	}
+	resurrected := false
+	if tombstone, ok := GetTombstone(target.Features); ok {
+		if _, removedVersion := target.getFieldPath(group.VersionField); eventVersion == nil || !removedVersion {
+			log.Println("WARNING: ignore event for removed target", group.Target, target.Id, tombstone.DeletedAt)
+			return nil
+		}
+		//the event is newer than the removed version; the tombstone will be overwritten
+		resurrected = true
+		target.Features = map[string]interface{}{}
+		target.Changed = true
+	}
+	previous := getSubscriptionSnapshot(target)
 	result, err := group.Actions.Do(target, temp, perm)
 	if err != nil {
 		return err
 	}
-	if result.New {
-		result, err = handleInit(result, group.Init, temp)
+	result.setChangeSource(meta.Topic, group.Target, group.index)
+	err = group.SetVersion(&result, eventVersion)
+	if err != nil {
+		return err
+	}
+	if result.New || resurrected {
+		result, err = handleInit(result, group.Init, temp, meta, group.index)
 		if err != nil {
 			return err
 		}
 	}
-	if result.Removed {
-		err = result.Remove()
-	} else if result.Changed {
-		err = result.Save()
+	err = persistTarget(result, group, previous)
+	if err != nil || !result.Removed {
+		return err
+	}
+	return cleanupRelations(result)
+}
+
+// saves or removes the target if it was changed by the actions
+// previous is used to notify subscriptions about targets that no longer match
+func persistTarget(target Target, group EventActionGroup, previous map[string]interface{}) (err error) {
+	oldVersion := target.Version
+	if target.Removed {
+		err = target.Remove()
+	} else if target.Changed {
+		err = target.Save()
+	} else {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if Config.ChangeLogging == "true" {
+		changes, _ := json.Marshal(target.Changes)
+		log.Println("CHANGE:", target.Name, target.Id, "removed:", target.Removed, "new:", target.New, string(changes))
 	}
-	return err
+	if group.ChangeTopic != "" {
+		publishTargetChange(group.ChangeTopic, group.ChangeProjection, target, oldVersion)
+	}
+	subscriptions.Notify(target, previous)
+	return nil
 }
 
-func handleInit(target Target, groups []InitActionGroup, temp map[string]interface{}) (Target, error) {
+// groupIndex is the index of the root group running the init actions
+func handleInit(target Target, groups []InitActionGroup, temp map[string]interface{}, meta EventMeta, groupIndex int) (Target, error) {
 	for _, group := range groups {
 		children, err := GetTargetsWhereSorted(group.Target, group.Where, temp, group.Sorting)
 		if err != nil {
@@ -132,7 +216,7 @@ func handleInit(target Target, groups []InitActionGroup, temp map[string]interfa
 			}
 		}
 		for _, child := range children {
-			temp, perm, err := MsgStructToFeatures(group.Transform, child.Features)
+			temp, perm, err := MsgStructToFeatures(group.Transform, child.Features, meta)
 			if err != nil {
 				return target, err
 			}
@@ -140,6 +224,7 @@ func handleInit(target Target, groups []InitActionGroup, temp map[string]interfa
 			if err != nil {
 				return target, err
 			}
+			target.setChangeSource(meta.Topic, "init:"+group.Target, groupIndex)
 		}
 	}
 	return target, nil
@@ -18,37 +18,419 @@ package lib
 
 import (
 	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compiled json path
+//
+// supported syntax: $ (root), .name, ['name'], ['a','b'], .* and [*], [0], [-1], [0,2], [start:end:step], ..name (recursive descent),
+// [?(@.a == 'x' && @.b > 2 || !@.c)] (filters with ==, !=, <, <=, >, >= and existence checks)
+//
+// paths ending with '+' use the compatibility mode of the previously used jsonpath library:
+// one result is returned as value, multiple results as list.
+// other paths return the value for definite paths (only names and single indexes) and a list for all other paths.
+// in both modes nil is returned if nothing is found.
+type JsonPath struct {
+	Source   string
+	compat   bool
+	definite bool
+	steps    []jsonPathStep
+}
+
+type jsonPathStepType int
 
-	"github.com/JumboInteractiveLimited/jsonpath"
+const (
+	jsonPathChildStep jsonPathStepType = iota
+	jsonPathIndexStep
+	jsonPathWildcardStep
+	jsonPathSliceStep
+	jsonPathFilterStep
 )
 
+type jsonPathStep struct {
+	kind      jsonPathStepType
+	recursive bool
+	names     []string
+	indexes   []int
+	slice     [3]*int
+	filter    jsonPathFilter
+}
+
+var jsonPathCache = map[string]*JsonPath{}
+var jsonPathCacheMux sync.RWMutex
+
+// returns the compiled path from cache or compiles and caches it
+func GetJsonPath(path string) (result *JsonPath, err error) {
+	jsonPathCacheMux.RLock()
+	result, ok := jsonPathCache[path]
+	jsonPathCacheMux.RUnlock()
+	if ok {
+		return result, nil
+	}
+	result, err = CompileJsonPath(path)
+	if err != nil {
+		return result, err
+	}
+	jsonPathCacheMux.Lock()
+	jsonPathCache[path] = result
+	jsonPathCacheMux.Unlock()
+	return result, nil
+}
+
 func UseJsonPath(msg []byte, path string) (interface{}, error) {
-	temp := []interface{}{}
-	paths, err := jsonpath.ParsePaths(path)
+	compiled, err := GetJsonPath(path)
 	if err != nil {
 		return nil, err
 	}
-	eval, err := jsonpath.EvalPathsInBytes(msg, paths)
+	var doc interface{}
+	err = json.Unmarshal(msg, &doc)
 	if err != nil {
 		return nil, err
 	}
-	for {
-		if element, ok := eval.Next(); ok {
-			var val interface{}
-			err = json.Unmarshal(element.Value, &val)
+	return compiled.Eval(doc), nil
+}
+
+func CompileJsonPath(path string) (result *JsonPath, err error) {
+	result = &JsonPath{Source: path}
+	p := strings.TrimSpace(path)
+	if strings.HasSuffix(p, "+") {
+		result.compat = true
+		p = strings.TrimSuffix(p, "+")
+	}
+	if !strings.HasPrefix(p, "$") {
+		return nil, errors.New("json path must start with '$': " + path)
+	}
+	result.steps, err = parseJsonPathSteps(p[1:])
+	if err != nil {
+		return nil, errors.New("invalid json path " + path + ": " + err.Error())
+	}
+	result.definite = true
+	for _, step := range result.steps {
+		if step.recursive || !((step.kind == jsonPathChildStep && len(step.names) == 1) || (step.kind == jsonPathIndexStep && len(step.indexes) == 1)) {
+			result.definite = false
+		}
+	}
+	return result, nil
+}
+
+// doc is expected to be the result of json.Unmarshal into a interface{} (other maps with string keys and slices are handled by reflection)
+func (this *JsonPath) Eval(doc interface{}) interface{} {
+	results := this.evalAll(doc)
+	if len(results) == 0 {
+		return nil
+	}
+	if (this.compat || this.definite) && len(results) == 1 {
+		return results[0]
+	}
+	return results
+}
+
+func (this *JsonPath) evalAll(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, step := range this.steps {
+		next := []interface{}{}
+		for _, node := range current {
+			if step.recursive {
+				for _, descendant := range jsonPathDescendants(node) {
+					next = append(next, step.apply(descendant)...)
+				}
+			} else {
+				next = append(next, step.apply(node)...)
+			}
+		}
+		current = next
+		if len(current) == 0 {
+			break
+		}
+	}
+	return current
+}
+
+func (this jsonPathStep) apply(node interface{}) (result []interface{}) {
+	switch this.kind {
+	case jsonPathChildStep:
+		if m, ok := jsonPathMap(node); ok {
+			for _, name := range this.names {
+				if val, ok := m[name]; ok {
+					result = append(result, val)
+				}
+			}
+		}
+	case jsonPathIndexStep:
+		if list, ok := jsonPathList(node); ok {
+			for _, index := range this.indexes {
+				if index < 0 {
+					index = len(list) + index
+				}
+				if index >= 0 && index < len(list) {
+					result = append(result, list[index])
+				}
+			}
+		}
+	case jsonPathWildcardStep:
+		return jsonPathChildren(node)
+	case jsonPathSliceStep:
+		if list, ok := jsonPathList(node); ok {
+			start, end, step := 0, len(list), 1
+			if this.slice[2] != nil && *this.slice[2] != 0 {
+				step = *this.slice[2]
+			}
+			if step < 0 {
+				start, end = len(list)-1, -len(list)-1
+			}
+			if this.slice[0] != nil {
+				start = *this.slice[0]
+			}
+			if this.slice[1] != nil {
+				end = *this.slice[1]
+			}
+			if start < 0 {
+				start = len(list) + start
+			}
+			if end < 0 {
+				end = len(list) + end
+			}
+			for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+				if i >= 0 && i < len(list) {
+					result = append(result, list[i])
+				}
+			}
+		}
+	case jsonPathFilterStep:
+		for _, child := range jsonPathChildren(node) {
+			if this.filter.check(child) {
+				result = append(result, child)
+			}
+		}
+	}
+	return
+}
+
+func jsonPathMap(node interface{}) (map[string]interface{}, bool) {
+	switch m := node.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Features:
+		return m, true
+	}
+	if node == nil || reflect.TypeOf(node).Kind() != reflect.Map || reflect.TypeOf(node).Key().Kind() != reflect.String {
+		return nil, false
+	}
+	result := map[string]interface{}{}
+	value := reflect.ValueOf(node)
+	for _, key := range value.MapKeys() {
+		result[key.String()] = value.MapIndex(key).Interface()
+	}
+	return result, true
+}
+
+func jsonPathList(node interface{}) ([]interface{}, bool) {
+	if list, ok := node.([]interface{}); ok {
+		return list, true
+	}
+	if node == nil || reflect.TypeOf(node).Kind() != reflect.Slice {
+		return nil, false
+	}
+	list, err := InterfaceSlice(node)
+	return list, err == nil
+}
+
+// values of maps (sorted by key) or elements of lists
+func jsonPathChildren(node interface{}) (result []interface{}) {
+	if m, ok := jsonPathMap(node); ok {
+		keys := []string{}
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, m[key])
+		}
+		return
+	}
+	if list, ok := jsonPathList(node); ok {
+		return list
+	}
+	return
+}
+
+func jsonPathDescendants(node interface{}) (result []interface{}) {
+	result = append(result, node)
+	for _, child := range jsonPathChildren(node) {
+		result = append(result, jsonPathDescendants(child)...)
+	}
+	return
+}
+
+func parseJsonPathSteps(p string) (steps []jsonPathStep, err error) {
+	i := 0
+	for i < len(p) {
+		recursive := false
+		if strings.HasPrefix(p[i:], "..") {
+			recursive = true
+			i += 2
+		} else if p[i] == '.' {
+			i++
+		} else if p[i] != '[' {
+			return steps, errors.New("unexpected character '" + string(p[i]) + "' at " + strconv.Itoa(i))
+		}
+		var step jsonPathStep
+		if i < len(p) && p[i] == '[' {
+			var length int
+			step, length, err = parseJsonPathBracket(p[i:])
 			if err != nil {
-				return nil, err
+				return steps, err
 			}
-			temp = append(temp, val)
+			i += length
 		} else {
-			break
+			end := i
+			for end < len(p) && p[end] != '.' && p[end] != '[' {
+				end++
+			}
+			name := p[i:end]
+			if name == "" {
+				return steps, errors.New("missing name at " + strconv.Itoa(i))
+			}
+			if name == "*" {
+				step = jsonPathStep{kind: jsonPathWildcardStep}
+			} else {
+				step = jsonPathStep{kind: jsonPathChildStep, names: []string{name}}
+			}
+			i = end
 		}
+		step.recursive = recursive
+		steps = append(steps, step)
 	}
-	if len(temp) > 1 {
-		return temp, nil
+	return
+}
+
+// s starts with '['; returns the parsed step and the length of the bracket expression
+func parseJsonPathBracket(s string) (step jsonPathStep, length int, err error) {
+	end, err := findJsonPathClosing(s, 0)
+	if err != nil {
+		return step, 0, err
 	}
-	if len(temp) == 1 {
-		return temp[0], nil
+	length = end + 1
+	content := strings.TrimSpace(s[1:end])
+	switch {
+	case content == "*":
+		step.kind = jsonPathWildcardStep
+	case strings.HasPrefix(content, "?"):
+		expr := strings.TrimSpace(content[1:])
+		if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+			return step, 0, errors.New("filter must be enclosed in '?(' and ')'")
+		}
+		step.kind = jsonPathFilterStep
+		step.filter, err = parseJsonPathFilter(expr[1 : len(expr)-1])
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, "\""):
+		step.kind = jsonPathChildStep
+		for _, part := range splitJsonPathList(content) {
+			name, err := unquoteJsonPathString(part)
+			if err != nil {
+				return step, 0, err
+			}
+			step.names = append(step.names, name)
+		}
+	case strings.Contains(content, ":"):
+		step.kind = jsonPathSliceStep
+		parts := strings.Split(content, ":")
+		if len(parts) > 3 {
+			return step, 0, errors.New("invalid slice " + content)
+		}
+		for index, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			val, err := strconv.Atoi(part)
+			if err != nil {
+				return step, 0, errors.New("invalid slice " + content)
+			}
+			step.slice[index] = &val
+		}
+	default:
+		step.kind = jsonPathIndexStep
+		for _, part := range strings.Split(content, ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return step, 0, errors.New("invalid index " + part)
+			}
+			step.indexes = append(step.indexes, index)
+		}
+	}
+	return
+}
+
+// returns the index of the bracket closing the bracket at start; respects quotes and nesting
+func findJsonPathClosing(s string, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("missing closing bracket")
+}
+
+// splits on commas outside of quotes
+func splitJsonPathList(s string) (result []string) {
+	var quote byte
+	last := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+		}
+		if c == ',' {
+			result = append(result, strings.TrimSpace(s[last:i]))
+			last = i + 1
+		}
+	}
+	return append(result, strings.TrimSpace(s[last:]))
+}
+
+func unquoteJsonPathString(s string) (string, error) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", errors.New("invalid string " + s)
+	}
+	content := s[1 : len(s)-1]
+	result := strings.Builder{}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\\' && i+1 < len(content) {
+			i++
+		}
+		result.WriteByte(content[i])
 	}
-	return nil, nil
+	return result.String(), nil
 }
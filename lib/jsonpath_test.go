@@ -0,0 +1,189 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func ExampleJsonPath_Eval() {
+	msg := `{
+		"id": "gw_1",
+		"name with space": "foo",
+		"devices": [
+			{"id": "d1", "type": "lamp", "power": 10, "services": [{"id": "s1"}, {"id": "s2"}]},
+			{"id": "d2", "type": "sensor", "power": 2, "services": [{"id": "s3"}]},
+			{"id": "d3", "type": "lamp", "power": 60, "tag": null}
+		]
+	}`
+	var doc interface{}
+	json.Unmarshal([]byte(msg), &doc)
+
+	paths := []string{
+		"$.id+",
+		"$.id",
+		"$['name with space']",
+		"$.devices[0].id",
+		"$.devices[-1].id",
+		"$.devices[*].id",
+		"$.devices[*].id+",
+		"$.devices[0:2].id",
+		"$.devices[::-1].id",
+		"$.devices[0,2].id",
+		"$.devices[?(@.type == 'lamp')].id",
+		"$.devices[?(@.type == 'lamp' && @.power > 20)].id",
+		"$.devices[?(@.power <= 2 || @.id == 'd1')].id",
+		"$.devices[?(!@.services)].id",
+		"$.devices[?(@.tag)].id",
+		"$.devices[?(@.services[0].id == 's3')].id+",
+		"$..services[*].id",
+		"$.devices[*]['id','type']",
+		"$.devices[?(@.type == 'fridge')].id",
+		"$.unknown",
+		"$.unknown+",
+	}
+	for _, path := range paths {
+		compiled, err := CompileJsonPath(path)
+		if err != nil {
+			fmt.Println(path, err)
+			continue
+		}
+		fmt.Println(path, compiled.Eval(doc))
+	}
+
+	for _, path := range []string{"id", "$.", "$[0", "$[?(@.a ==)]", "$[?@.a]", "$.a[x]"} {
+		_, err := CompileJsonPath(path)
+		fmt.Println(err != nil)
+	}
+
+	//output:
+	//$.id+ gw_1
+	//$.id gw_1
+	//$['name with space'] foo
+	//$.devices[0].id d1
+	//$.devices[-1].id d3
+	//$.devices[*].id [d1 d2 d3]
+	//$.devices[*].id+ [d1 d2 d3]
+	//$.devices[0:2].id [d1 d2]
+	//$.devices[::-1].id [d3 d2 d1]
+	//$.devices[0,2].id [d1 d3]
+	//$.devices[?(@.type == 'lamp')].id [d1 d3]
+	//$.devices[?(@.type == 'lamp' && @.power > 20)].id [d3]
+	//$.devices[?(@.power <= 2 || @.id == 'd1')].id [d1 d2]
+	//$.devices[?(!@.services)].id [d3]
+	//$.devices[?(@.tag)].id [d3]
+	//$.devices[?(@.services[0].id == 's3')].id+ d2
+	//$..services[*].id [s1 s2 s3]
+	//$.devices[*]['id','type'] [d1 lamp d2 sensor d3 lamp]
+	//$.devices[?(@.type == 'fridge')].id <nil>
+	//$.unknown <nil>
+	//$.unknown+ <nil>
+	//true
+	//true
+	//true
+	//true
+	//true
+	//true
+}
+
+var benchmarkMsg = []byte(`{
+	"command": "PUT",
+	"id": "device_1",
+	"owner": "user_1",
+	"device_instance": {
+		"name": "lamp",
+		"device_type": "type_1",
+		"uri": "uri_1",
+		"img": "img_1",
+		"tags": ["a", "b"],
+		"user_tags": ["c"],
+		"services": [{"id": "s1"}, {"id": "s2"}, {"id": "s3"}]
+	}
+}`)
+
+var benchmarkFeatures = []Feature{
+	{Name: "command", Path: "$.command+", Temp: true},
+	{Name: "id", Path: "$.id+"},
+	{Name: "owner", Path: "$.owner+"},
+	{Name: "name", Path: "$.device_instance.name+"},
+	{Name: "devicetype", Path: "$.device_instance.device_type+"},
+	{Name: "uri", Path: "$.device_instance.uri+"},
+	{Name: "img", Path: "$.device_instance.img+"},
+	{Name: "tag", Path: "$.device_instance.tags+"},
+	{Name: "usertag", Path: "$.device_instance.user_tags+"},
+	{Name: "services", Path: "$.device_instance.services[*].id+"},
+}
+
+// per event cost of the feature extraction with paths compiled on config load
+func BenchmarkMsgToFeatures(b *testing.B) {
+	features := append([]Feature{}, benchmarkFeatures...)
+	err := CompileFeatures(features)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, err := MsgToFeatures(features, benchmarkMsg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// per event cost of the feature extraction with uncompiled features (global path cache)
+func BenchmarkMsgToFeaturesCached(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _, err := MsgToFeatures(benchmarkFeatures, benchmarkMsg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompileJsonPath(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := CompileJsonPath("$.devices[?(@.type == 'lamp' && @.power > 20)].services[*].id")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// per event cost for a topic with several groups; the event is decoded once and shared by all groups
+func BenchmarkEventToFeaturesGroups(b *testing.B) {
+	features := append([]Feature{}, benchmarkFeatures...)
+	err := CompileFeatures(features)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event := NewEvent(benchmarkMsg, EventMeta{})
+		for group := 0; group < 5; group++ {
+			_, _, err := EventToFeatures(features, event)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// filter expression of a json path ([?(...)]), checked against each child of the current node
+type jsonPathFilter interface {
+	check(node interface{}) bool
+}
+
+type jsonPathOr []jsonPathFilter
+
+type jsonPathAnd []jsonPathFilter
+
+type jsonPathNot struct {
+	filter jsonPathFilter
+}
+
+type jsonPathComparison struct {
+	left     jsonPathOperand
+	right    jsonPathOperand
+	operator string
+}
+
+// either a path relative to the checked node (@.name) or a literal
+type jsonPathOperand struct {
+	path    *JsonPath
+	literal interface{}
+}
+
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (this jsonPathOr) check(node interface{}) bool {
+	for _, filter := range this {
+		if filter.check(node) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this jsonPathAnd) check(node interface{}) bool {
+	for _, filter := range this {
+		if !filter.check(node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (this jsonPathNot) check(node interface{}) bool {
+	return !this.filter.check(node)
+}
+
+func (this jsonPathComparison) check(node interface{}) bool {
+	left, leftFound := this.left.value(node)
+	if this.operator == "" {
+		return leftFound
+	}
+	right, rightFound := this.right.value(node)
+	if !leftFound || !rightFound {
+		return this.operator == "!="
+	}
+	switch this.operator {
+	case "==":
+		return jsonPathEqual(left, right)
+	case "!=":
+		return !jsonPathEqual(left, right)
+	}
+	compare, ok := jsonPathCompare(left, right)
+	if !ok {
+		return false
+	}
+	switch this.operator {
+	case "<":
+		return compare < 0
+	case "<=":
+		return compare <= 0
+	case ">":
+		return compare > 0
+	case ">=":
+		return compare >= 0
+	}
+	return false
+}
+
+func (this jsonPathOperand) value(node interface{}) (interface{}, bool) {
+	if this.path == nil {
+		return this.literal, true
+	}
+	results := this.path.evalAll(node)
+	if len(results) == 0 {
+		return nil, false
+	}
+	if this.path.definite {
+		return results[0], true
+	}
+	return results, true
+}
+
+func jsonPathNumber(value interface{}) (float64, bool) {
+	if value == nil || reflect.TypeOf(value).Kind() == reflect.String {
+		return 0, false
+	}
+	number, ok := toNumber(value).(float64)
+	return number, ok
+}
+
+func jsonPathEqual(a interface{}, b interface{}) bool {
+	aNumber, aOk := jsonPathNumber(a)
+	bNumber, bOk := jsonPathNumber(b)
+	if aOk && bOk {
+		return aNumber == bNumber
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func jsonPathCompare(a interface{}, b interface{}) (int, bool) {
+	aNumber, aOk := jsonPathNumber(a)
+	bNumber, bOk := jsonPathNumber(b)
+	if aOk && bOk {
+		switch {
+		case aNumber < bNumber:
+			return -1, true
+		case aNumber > bNumber:
+			return 1, true
+		}
+		return 0, true
+	}
+	aStr, aOk := a.(string)
+	bStr, bOk := b.(string)
+	if aOk && bOk {
+		return strings.Compare(aStr, bStr), true
+	}
+	return 0, false
+}
+
+type jsonPathFilterParser struct {
+	s string
+	i int
+}
+
+func parseJsonPathFilter(expr string) (result jsonPathFilter, err error) {
+	parser := &jsonPathFilterParser{s: expr}
+	result, err = parser.parseOr()
+	if err != nil {
+		return result, err
+	}
+	parser.skipSpace()
+	if parser.i < len(parser.s) {
+		return result, errors.New("unexpected filter content " + parser.s[parser.i:])
+	}
+	return result, nil
+}
+
+func (this *jsonPathFilterParser) skipSpace() {
+	for this.i < len(this.s) && (this.s[this.i] == ' ' || this.s[this.i] == '\t') {
+		this.i++
+	}
+}
+
+func (this *jsonPathFilterParser) consume(token string) bool {
+	this.skipSpace()
+	if strings.HasPrefix(this.s[this.i:], token) {
+		this.i += len(token)
+		return true
+	}
+	return false
+}
+
+func (this *jsonPathFilterParser) parseOr() (jsonPathFilter, error) {
+	first, err := this.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	result := jsonPathOr{first}
+	for this.consume("||") {
+		next, err := this.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, next)
+	}
+	if len(result) == 1 {
+		return first, nil
+	}
+	return result, nil
+}
+
+func (this *jsonPathFilterParser) parseAnd() (jsonPathFilter, error) {
+	first, err := this.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	result := jsonPathAnd{first}
+	for this.consume("&&") {
+		next, err := this.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, next)
+	}
+	if len(result) == 1 {
+		return first, nil
+	}
+	return result, nil
+}
+
+func (this *jsonPathFilterParser) parseUnary() (jsonPathFilter, error) {
+	if this.consume("!") {
+		inner, err := this.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathNot{filter: inner}, nil
+	}
+	if this.consume("(") {
+		inner, err := this.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !this.consume(")") {
+			return nil, errors.New("missing ')' in filter")
+		}
+		return inner, nil
+	}
+	return this.parseComparison()
+}
+
+func (this *jsonPathFilterParser) parseComparison() (result jsonPathComparison, err error) {
+	result.left, err = this.parseOperand()
+	if err != nil {
+		return result, err
+	}
+	for _, operator := range jsonPathOperators {
+		if this.consume(operator) {
+			result.operator = operator
+			result.right, err = this.parseOperand()
+			return result, err
+		}
+	}
+	if result.left.path == nil {
+		return result, errors.New("filter condition without path")
+	}
+	return result, nil
+}
+
+func (this *jsonPathFilterParser) parseOperand() (result jsonPathOperand, err error) {
+	this.skipSpace()
+	if this.i >= len(this.s) {
+		return result, errors.New("missing filter operand")
+	}
+	start := this.i
+	switch this.s[this.i] {
+	case '@':
+		for this.i < len(this.s) {
+			c := this.s[this.i]
+			if c == '[' {
+				end, err := findJsonPathClosing(this.s, this.i)
+				if err != nil {
+					return result, err
+				}
+				this.i = end + 1
+				continue
+			}
+			if strings.ContainsRune(" \t=!<>&|)", rune(c)) {
+				break
+			}
+			this.i++
+		}
+		result.path, err = CompileJsonPath("$" + this.s[start+1:this.i])
+		return result, err
+	case '\'', '"':
+		quote := this.s[this.i]
+		this.i++
+		for this.i < len(this.s) && this.s[this.i] != quote {
+			if this.s[this.i] == '\\' {
+				this.i++
+			}
+			this.i++
+		}
+		if this.i >= len(this.s) {
+			return result, errors.New("unterminated string in filter")
+		}
+		this.i++
+		result.literal, err = unquoteJsonPathString(this.s[start:this.i])
+		return result, err
+	}
+	for this.i < len(this.s) && !strings.ContainsRune(" \t=!<>&|)", rune(this.s[this.i])) {
+		this.i++
+	}
+	err = json.Unmarshal([]byte(this.s[start:this.i]), &result.literal)
+	if err != nil {
+		return result, errors.New("invalid filter literal " + this.s[start:this.i])
+	}
+	return result, nil
+}
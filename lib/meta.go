@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// reserved path prefix to access the EventMeta of a event as feature (for example "$meta.headers.version+")
+const MetaPathPrefix = "$meta"
+
+// delivery information of the event that is currently processed
+type EventMeta struct {
+	Topic         string
+	ReceivedAt    time.Time
+	Headers       map[string]interface{}
+	RoutingKey    string
+	Exchange      string
+	MessageId     string
+	CorrelationId string
+	ContentType   string
+	Type          string
+	AppId         string
+	UserId        string
+	Timestamp     time.Time
+	Redelivered   bool
+}
+
+func DeliveryToEventMeta(topic string, delivery amqp.Delivery) EventMeta {
+	headers, _ := normalizeMetaValue(map[string]interface{}(delivery.Headers)).(map[string]interface{})
+	return EventMeta{
+		Topic:         topic,
+		ReceivedAt:    time.Now(),
+		Headers:       headers,
+		RoutingKey:    delivery.RoutingKey,
+		Exchange:      delivery.Exchange,
+		MessageId:     delivery.MessageId,
+		CorrelationId: delivery.CorrelationId,
+		ContentType:   delivery.ContentType,
+		Type:          delivery.Type,
+		AppId:         delivery.AppId,
+		UserId:        delivery.UserId,
+		Timestamp:     delivery.Timestamp,
+		Redelivered:   delivery.Redelivered,
+	}
+}
+
+// json like representation of the meta data; empty values are omitted and times are unix timestamps in milliseconds
+func (this EventMeta) ToMap() (result map[string]interface{}) {
+	result = map[string]interface{}{}
+	setIfNotEmpty := func(key string, value interface{}) {
+		if !isEmpty(value) {
+			result[key] = value
+		}
+	}
+	setIfNotEmpty("topic", this.Topic)
+	setIfNotEmpty("routing_key", this.RoutingKey)
+	setIfNotEmpty("exchange", this.Exchange)
+	setIfNotEmpty("message_id", this.MessageId)
+	setIfNotEmpty("correlation_id", this.CorrelationId)
+	setIfNotEmpty("content_type", this.ContentType)
+	setIfNotEmpty("type", this.Type)
+	setIfNotEmpty("app_id", this.AppId)
+	setIfNotEmpty("user_id", this.UserId)
+	if !this.ReceivedAt.IsZero() {
+		result["received_at"] = normalizeMetaValue(this.ReceivedAt)
+	}
+	if !this.Timestamp.IsZero() {
+		result["timestamp"] = normalizeMetaValue(this.Timestamp)
+	}
+	if this.Headers != nil {
+		result["headers"] = this.Headers
+	}
+	result["redelivered"] = this.Redelivered
+	return
+}
+
+// "$meta.headers.version+" -> value of the header "version"
+func UseMetaPath(meta EventMeta, path string) (result interface{}, err error) {
+	compiled, err := GetJsonPath(jsonPathSource(path))
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Eval(meta.ToMap()), nil
+}
+
+// meta paths are evaluated as json path on EventMeta.ToMap()
+func jsonPathSource(path string) string {
+	if isMetaPath(path) {
+		return "$" + strings.TrimPrefix(path, MetaPathPrefix)
+	}
+	return path
+}
+
+func isMetaPath(path string) bool {
+	return path == MetaPathPrefix || strings.HasPrefix(path, MetaPathPrefix+".") || path == MetaPathPrefix+"+"
+}
+
+// converts amqp header values to the types produced by json decoding (numbers as float64, tables as maps)
+func normalizeMetaValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return float64(v.UnixNano() / int64(time.Millisecond))
+	case []byte:
+		return string(v)
+	case amqp.Decimal:
+		f := float64(v.Value)
+		for i := uint8(0); i < v.Scale; i++ {
+			f = f / 10
+		}
+		return f
+	case amqp.Table:
+		return normalizeMetaValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, element := range v {
+			result[key] = normalizeMetaValue(element)
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, element := range v {
+			result = append(result, normalizeMetaValue(element))
+		}
+		return result
+	}
+	number := toNumber(value)
+	if number != nil && reflect.TypeOf(value).Kind() != reflect.String {
+		return number
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func ExampleUseMetaPath() {
+	delivery := amqp.Delivery{
+		Headers: amqp.Table{
+			"version": int32(3),
+			"tenant":  "t1",
+			"trace":   amqp.Table{"id": []byte("trace_1")},
+		},
+		RoutingKey: "rk",
+		MessageId:  "msg_1",
+		Timestamp:  time.Date(2018, time.October, 18, 12, 0, 0, 0, time.UTC),
+		Body:       []byte(`{"id": "device_1"}`),
+	}
+	meta := DeliveryToEventMeta("deviceinstance", delivery)
+
+	features := []Feature{
+		{Name: "id", Path: "$.id+"},
+		{Name: "version", Path: "$meta.headers.version+"},
+		{Name: "tenant", Path: "$meta.headers.tenant"},
+		{Name: "trace", Path: "$meta.headers.trace.id+"},
+		{Name: "routing_key", Path: "$meta.routing_key+"},
+		{Name: "message_id", Path: "$meta.message_id+"},
+		{Name: "timestamp", Path: "$meta.timestamp+"},
+		{Name: "topic", Path: "$meta.topic+"},
+		{Name: "missing", Path: "$meta.headers.foo+", Default: "default"},
+	}
+	_, perm, err := MsgWithMetaToFeatures(features, delivery.Body, meta)
+	fmt.Println(err)
+	for _, feature := range features {
+		fmt.Printf("%s: %#v\n", feature.Name, perm[feature.Name])
+	}
+
+	//output:
+	//<nil>
+	//id: "device_1"
+	//version: 3
+	//tenant: "t1"
+	//trace: "trace_1"
+	//routing_key: "rk"
+	//message_id: "msg_1"
+	//timestamp: 1.539864e+12
+	//topic: "deviceinstance"
+	//missing: "default"
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"expvar"
+)
+
+// event counters by topic; not published as global expvar variables, so /metrics only serves these counters
+var (
+	eventsReceived  = new(expvar.Map).Init()
+	eventsRejected  = new(expvar.Map).Init()
+	eventsFailed    = new(expvar.Map).Init()
+	eventsDuplicate = new(expvar.Map).Init()
+)
+
+func GetMetrics() map[string]map[string]int64 {
+	return map[string]map[string]int64{
+		"events_received":  getCounters(eventsReceived),
+		"events_rejected":  getCounters(eventsRejected),
+		"events_failed":    getCounters(eventsFailed),
+		"events_duplicate": getCounters(eventsDuplicate),
+	}
+}
+
+func getCounters(counters *expvar.Map) map[string]int64 {
+	result := map[string]int64{}
+	counters.Do(func(kv expvar.KeyValue) {
+		if counter, ok := kv.Value.(*expvar.Int); ok {
+			result[kv.Key] = counter.Value()
+		}
+	})
+	return result
+}
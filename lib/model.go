@@ -16,6 +16,10 @@
 
 package lib
 
+import (
+	"errors"
+)
+
 type EventsConfig map[string][]EventActionGroup
 
 type QueriesConfig map[string]QueryConfig
@@ -27,6 +31,43 @@ type QueryEndpoint struct {
 	Projection Projection      `json:"projection"`
 }
 
+// compiles the feature paths of all groups; invalid paths are reported on config load instead of on the first event
+func (this EventsConfig) CompileFeatures() error {
+	for topic, groups := range this {
+		for _, group := range groups {
+			err := CompileFeatures(group.Features)
+			if err != nil {
+				return errors.New("topic " + topic + ": " + err.Error())
+			}
+			for _, init := range group.Init {
+				err = CompileFeatures(init.Transform)
+				if err != nil {
+					return errors.New("topic " + topic + " init: " + err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (this EventsConfig) ValidateActions() error {
+	for topic, groups := range this {
+		for _, group := range groups {
+			err := group.Actions.Validate()
+			if err != nil {
+				return errors.New("topic " + topic + ": " + err.Error())
+			}
+			for _, init := range group.Init {
+				err = init.Actions.Validate()
+				if err != nil {
+					return errors.New("topic " + topic + " init: " + err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (this EventsConfig) GetTopicList() (result []string) {
 	for topic := range this {
 		result = append(result, topic)
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// json patch operation as described in RFC 6902
+type JsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+type JsonPatch []JsonPatchOperation
+
+// returned if a test operation does not match; the patch is not applied, but the patch itself is valid
+type JsonPatchTestError struct {
+	Path string
+}
+
+func (this JsonPatchTestError) Error() string {
+	return "json patch: test failed for " + this.Path
+}
+
+// interprets a decoded json value (for example a event feature) as json patch
+func ParseJsonPatch(value interface{}) (result JsonPatch, err error) {
+	temp, err := json.Marshal(value)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(temp, &result)
+	return
+}
+
+// doc is not modified; if one operation fails, no operation is applied
+func (this JsonPatch) Apply(doc interface{}) (result interface{}, err error) {
+	result = copyJsonValue(doc)
+	for _, operation := range this {
+		result, err = operation.apply(result)
+		if err != nil {
+			return doc, err
+		}
+	}
+	return result, nil
+}
+
+func (this JsonPatchOperation) apply(doc interface{}) (result interface{}, err error) {
+	path, err := parseJsonPointer(this.Path)
+	if err != nil {
+		return doc, err
+	}
+	switch this.Op {
+	case "add":
+		return jsonPointerAdd(doc, path, copyJsonValue(this.Value))
+	case "remove":
+		result, _, err = jsonPointerRemove(doc, path)
+		return result, err
+	case "replace":
+		if len(path) == 0 {
+			return copyJsonValue(this.Value), nil
+		}
+		result, _, err = jsonPointerRemove(doc, path)
+		if err != nil {
+			return doc, err
+		}
+		return jsonPointerAdd(result, path, copyJsonValue(this.Value))
+	case "move":
+		from, err := parseJsonPointer(this.From)
+		if err != nil {
+			return doc, err
+		}
+		if strings.HasPrefix(this.Path+"/", this.From+"/") && this.Path != this.From {
+			return doc, errors.New("json patch: unable to move " + this.From + " into its own child " + this.Path)
+		}
+		result, value, err := jsonPointerRemove(doc, from)
+		if err != nil {
+			return doc, err
+		}
+		return jsonPointerAdd(result, path, value)
+	case "copy":
+		from, err := parseJsonPointer(this.From)
+		if err != nil {
+			return doc, err
+		}
+		value, err := jsonPointerGet(doc, from)
+		if err != nil {
+			return doc, err
+		}
+		return jsonPointerAdd(doc, path, copyJsonValue(value))
+	case "test":
+		value, err := jsonPointerGet(doc, path)
+		if err != nil {
+			return doc, err
+		}
+		if !reflect.DeepEqual(value, this.Value) {
+			return doc, JsonPatchTestError{Path: this.Path}
+		}
+		return doc, nil
+	default:
+		return doc, errors.New("json patch: unknown operation " + this.Op)
+	}
+}
+
+// json pointer as described in RFC 6901; "" references the whole document
+func parseJsonPointer(pointer string) (result []string, err error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return result, errors.New("json patch: invalid path " + pointer)
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		result = append(result, strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1))
+	}
+	return
+}
+
+func jsonPointerIndex(token string, length int) (index int, err error) {
+	index, err = strconv.Atoi(token)
+	if err != nil || index < 0 || index >= length {
+		return index, errors.New("json patch: invalid index " + token)
+	}
+	return
+}
+
+func jsonPointerGet(doc interface{}, path []string) (result interface{}, err error) {
+	if len(path) == 0 {
+		return doc, nil
+	}
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[path[0]]
+		if !ok {
+			return nil, errors.New("json patch: missing field " + path[0])
+		}
+		return jsonPointerGet(child, path[1:])
+	case []interface{}:
+		index, err := jsonPointerIndex(path[0], len(container))
+		if err != nil {
+			return nil, err
+		}
+		return jsonPointerGet(container[index], path[1:])
+	default:
+		return nil, errors.New("json patch: unable to traverse " + path[0])
+	}
+}
+
+func jsonPointerAdd(doc interface{}, path []string, value interface{}) (result interface{}, err error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	key := path[0]
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			container[key] = value
+			return container, nil
+		}
+		child, ok := container[key]
+		if !ok {
+			return doc, errors.New("json patch: missing field " + key)
+		}
+		container[key], err = jsonPointerAdd(child, path[1:], value)
+		return container, err
+	case []interface{}:
+		if len(path) == 1 {
+			if key == "-" {
+				return append(container, value), nil
+			}
+			index, err := jsonPointerIndex(key, len(container)+1)
+			if err != nil {
+				return doc, err
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+			return container, nil
+		}
+		index, err := jsonPointerIndex(key, len(container))
+		if err != nil {
+			return doc, err
+		}
+		container[index], err = jsonPointerAdd(container[index], path[1:], value)
+		return container, err
+	default:
+		return doc, errors.New("json patch: unable to traverse " + key)
+	}
+}
+
+func jsonPointerRemove(doc interface{}, path []string) (result interface{}, removed interface{}, err error) {
+	if len(path) == 0 {
+		return doc, nil, errors.New("json patch: unable to remove the whole document")
+	}
+	key := path[0]
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[key]
+		if !ok {
+			return doc, nil, errors.New("json patch: missing field " + key)
+		}
+		if len(path) == 1 {
+			delete(container, key)
+			return container, child, nil
+		}
+		container[key], removed, err = jsonPointerRemove(child, path[1:])
+		return container, removed, err
+	case []interface{}:
+		index, err := jsonPointerIndex(key, len(container))
+		if err != nil {
+			return doc, nil, err
+		}
+		if len(path) == 1 {
+			removed = container[index]
+			return append(container[:index], container[index+1:]...), removed, nil
+		}
+		container[index], removed, err = jsonPointerRemove(container[index], path[1:])
+		return container, removed, err
+	default:
+		return doc, nil, errors.New("json patch: unable to traverse " + key)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func ExampleJsonPatch_Apply() {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"name": "foo", "tags": ["a", "b"], "meta": {"owner": "u1", "a/b": 1}}`), &doc)
+
+	patches := []string{
+		`[{"op": "replace", "path": "/name", "value": "bar"}]`,
+		`[{"op": "add", "path": "/tags/1", "value": "x"}, {"op": "add", "path": "/tags/-", "value": "z"}]`,
+		`[{"op": "remove", "path": "/meta/a~1b"}]`,
+		`[{"op": "move", "from": "/meta/owner", "path": "/owner"}]`,
+		`[{"op": "copy", "from": "/tags", "path": "/meta/tags"}]`,
+		`[{"op": "test", "path": "/name", "value": "foo"}, {"op": "add", "path": "/tested", "value": true}]`,
+		`[{"op": "add", "path": "/name", "value": "bar"}, {"op": "test", "path": "/name", "value": "foo"}]`,
+		`[{"op": "remove", "path": "/unknown"}]`,
+		`[{"op": "add", "path": "/tags/5", "value": "x"}]`,
+		`[{"op": "foo", "path": "/name"}]`,
+	}
+	for _, patchStr := range patches {
+		var patchValue interface{}
+		json.Unmarshal([]byte(patchStr), &patchValue)
+		patch, err := ParseJsonPatch(patchValue)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		result, err := patch.Apply(doc)
+		out, _ := json.Marshal(result)
+		fmt.Println(string(out), err)
+	}
+	out, _ := json.Marshal(doc)
+	fmt.Println(string(out))
+
+	//Output:
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"bar","tags":["a","b"]} <nil>
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","x","b","z"]} <nil>
+	//{"meta":{"owner":"u1"},"name":"foo","tags":["a","b"]} <nil>
+	//{"meta":{"a/b":1},"name":"foo","owner":"u1","tags":["a","b"]} <nil>
+	//{"meta":{"a/b":1,"owner":"u1","tags":["a","b"]},"name":"foo","tags":["a","b"]} <nil>
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","b"],"tested":true} <nil>
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","b"]} json patch: test failed for /name
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","b"]} json patch: missing field unknown
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","b"]} json patch: invalid index 5
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","b"]} json patch: unknown operation foo
+	//{"meta":{"a/b":1,"owner":"u1"},"name":"foo","tags":["a","b"]}
+}
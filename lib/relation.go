@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"errors"
+)
+
+// documents of target reference documents of the references target;
+// if a referenced document is removed, the actions are executed on all referencing documents found by where
+type Relation struct {
+	Target     string          `json:"target"`
+	References string          `json:"references"`
+	Field      string          `json:"field"`   //field of target containing the id of the referenced document; used if where is empty
+	Where      WhereConditions `json:"where"`   //event_feature references the fields of the removed document and its 'id'
+	Actions    Actions         `json:"actions"` //default: remove_target
+
+	ChangeTopic      string     `json:"change_topic"`      //receives a TargetChangeEvent for each document changed or removed by the relation
+	ChangeProjection Projection `json:"change_projection"` //document fields included in change events
+}
+
+func (this Relation) GetWhere() WhereConditions {
+	if len(this.Where) > 0 {
+		return this.Where
+	}
+	return WhereConditions{{TargetFeature: this.Field, Operation: WhereEqualOperation, EventFeature: "id"}}
+}
+
+func (this Relation) Validate() error {
+	if this.Target == "" || this.References == "" {
+		return errors.New("relation needs target and references")
+	}
+	if this.Field == "" && len(this.Where) == 0 {
+		return errors.New("relation of " + this.Target + " to " + this.References + " needs field or where")
+	}
+	return this.Actions.Validate()
+}
+
+func validateRelations(relations []Relation) error {
+	for _, relation := range relations {
+		if err := relation.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pseudo group used to persist documents changed by the relation
+func (this Relation) getGroup() EventActionGroup {
+	return EventActionGroup{Target: this.Target, ChangeTopic: this.ChangeTopic, ChangeProjection: this.ChangeProjection}
+}
+
+func (this Relation) GetActions() Actions {
+	if len(this.Actions) > 0 {
+		return this.Actions
+	}
+	return Actions{{Type: RemoveTargetAction}}
+}
+
+// fields of the removed document and its id as 'id'
+func GetRelationFeatures(removed Target) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, value := range removed.Features {
+		result[key] = value
+	}
+	result["id"] = removed.Id
+	return result
+}
+
+// runs the relation actions for the removed target; removed referencing documents are handled recursively
+func cleanupRelations(removed Target) error {
+	return cleanupRelationsRecursive(removed, map[string]bool{removed.Name + "/" + removed.Id: true})
+}
+
+func cleanupRelationsRecursive(removed Target, visited map[string]bool) error {
+	if Config == nil {
+		return nil
+	}
+	features := GetRelationFeatures(removed)
+	for _, relation := range Config.Relations {
+		if relation.References != removed.Name {
+			continue
+		}
+		targets, err := GetAllTargetsWhere(relation.Target, relation.GetWhere(), features)
+		if err != nil {
+			return err
+		}
+		for _, target := range targets {
+			key := target.Name + "/" + target.Id
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			previous := getSubscriptionSnapshot(target)
+			result, err := relation.GetActions().Do(target, features, features)
+			if err != nil {
+				return err
+			}
+			result.setChangeSource("", "relation:"+relation.References, 0)
+			err = persistTarget(result, relation.getGroup(), previous)
+			if err != nil {
+				return err
+			}
+			if result.Removed {
+				err = cleanupRelationsRecursive(result, visited)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
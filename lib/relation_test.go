@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func ExampleRelation_GetWhere() {
+	relations := []Relation{}
+	err := json.Unmarshal([]byte(`[
+		{"target": "deviceinstance", "references": "devicetype", "field": "devicetype.id"},
+		{
+			"target": "gateway",
+			"references": "deviceinstance",
+			"where": [{"target_feature": "devices.id", "operation": "==", "event_feature": "id"}],
+			"actions": [{"type": "remove", "fields": ["devices"], "scale": "many", "key": "id"}],
+			"change_topic": "gateway_changes"
+		}
+	]`), &relations)
+	fmt.Println(err)
+
+	removed := Target{Name: "deviceinstance", Id: "d1", Features: map[string]interface{}{"name": "foo"}}
+	features := GetRelationFeatures(removed)
+	fmt.Println(features)
+
+	for _, relation := range relations {
+		filter, err := relation.GetWhere().ToElasticFilter(features)
+		source, _ := filter[0].Source()
+		out, _ := json.Marshal(source)
+		fmt.Println(string(out), err)
+	}
+
+	gateway := Target{Name: "gateway", Id: "g1", Features: map[string]interface{}{"devices": []interface{}{
+		map[string]interface{}{"id": "d1", "name": "foo"},
+		map[string]interface{}{"id": "d2", "name": "bar"},
+	}}}
+	result, err := relations[1].GetActions().Do(gateway, features, features)
+	fmt.Println(result.Features, result.Changed, result.Removed, err)
+
+	result, err = relations[0].GetActions().Do(Target{Name: "deviceinstance", Id: "d1"}, features, features)
+	fmt.Println(result.Changed, result.Removed, err)
+
+	fmt.Printf("%q %q\n", relations[0].getGroup().ChangeTopic, relations[1].getGroup().ChangeTopic)
+
+	fmt.Println(validateRelations(relations))
+	fmt.Println(validateRelations([]Relation{{Target: "deviceinstance", References: "devicetype"}}))
+
+	//Output:
+	//<nil>
+	//map[id:d1 name:foo]
+	//{"term":{"devicetype.id":"d1"}} <nil>
+	//{"term":{"devices.id":"d1"}} <nil>
+	//map[devices:[map[id:d2 name:bar]]] true false <nil>
+	//true true <nil>
+	//"" "gateway_changes"
+	//<nil>
+	//relation of deviceinstance to devicetype needs field or where
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"log"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// published to the error topic if a event does not match the json schema of its topic or group
+type RejectedEvent struct {
+	Topic      string   `json:"topic"`
+	Target     string   `json:"target,omitempty"`
+	Errors     []string `json:"errors"`
+	Payload    string   `json:"payload"`
+	ReceivedAt int64    `json:"received_at"`
+}
+
+// returns nil if no schema is configured
+func CompileEventSchema(schema interface{}) (result *gojsonschema.Schema, err error) {
+	if schema == nil {
+		return nil, nil
+	}
+	return gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+}
+
+// returns the validation errors; events that are no valid json are reported as validation error
+func ValidateEvent(schema *gojsonschema.Schema, event *Event) (validationErrors []string, err error) {
+	if schema == nil {
+		return nil, nil
+	}
+	doc, err := event.Doc()
+	if err != nil {
+		return []string{"invalid json: " + err.Error()}, nil
+	}
+	result, err := schema.Validate(gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return nil, err
+	}
+	for _, validationError := range result.Errors() {
+		validationErrors = append(validationErrors, validationError.String())
+	}
+	return validationErrors, nil
+}
+
+// publishes the event to the error topic of the event topic (if configured) and counts it as rejected
+// returns a error only if the event could not be published to the error topic, so that it will be redelivered;
+// failed publishing is counted as failed event, so that redeliveries are not counted as rejected again
+func rejectEvent(event *Event, target string, validationErrors []string) error {
+	topic := event.Meta.Topic
+	log.Println("WARNING: reject invalid event", topic, target, validationErrors)
+	errorTopic := getErrorTopic(topic)
+	if errorTopic != "" {
+		err := sendEvent(errorTopic, RejectedEvent{
+			Topic:      topic,
+			Target:     target,
+			Errors:     validationErrors,
+			Payload:    string(event.Body()),
+			ReceivedAt: event.Meta.ReceivedAt.UnixNano() / int64(time.Millisecond),
+		})
+		if err != nil {
+			eventsFailed.Add(topic, 1)
+			return err
+		}
+	}
+	eventsRejected.Add(topic, 1)
+	return nil
+}
+
+func getErrorTopic(topic string) string {
+	if Config == nil {
+		return ""
+	}
+	if topicConfig, ok := Config.Topics[topic]; ok && topicConfig.ErrorTopic != "" {
+		return topicConfig.ErrorTopic
+	}
+	return Config.EventErrorTopic
+}
+
+// error topics have to be declared together with the consumed topics
+func getErrorTopics() (result []string) {
+	known := map[string]bool{}
+	for topic := range Config.Events {
+		errorTopic := getErrorTopic(topic)
+		if errorTopic != "" && !known[errorTopic] {
+			known[errorTopic] = true
+			result = append(result, errorTopic)
+		}
+	}
+	return
+}
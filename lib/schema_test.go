@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func ExampleValidateEvent() {
+	schemaStr := `{
+		"type": "object",
+		"required": ["device_id", "value"],
+		"properties": {
+			"device_id": {"type": "string"},
+			"value": {"type": "number"}
+		}
+	}`
+	var schemaDoc interface{}
+	json.Unmarshal([]byte(schemaStr), &schemaDoc)
+	schema, err := CompileEventSchema(schemaDoc)
+	fmt.Println(err)
+
+	fmt.Println(ValidateEvent(schema, NewEvent([]byte(`{"device_id": "d1", "value": 42}`), EventMeta{})))
+	fmt.Println(ValidateEvent(schema, NewEvent([]byte(`{"device_id": "d1", "value": "42"}`), EventMeta{})))
+	fmt.Println(ValidateEvent(schema, NewEvent([]byte(`{"value": 42}`), EventMeta{})))
+	fmt.Println(ValidateEvent(schema, NewEvent([]byte(`{"device_id": "d1"`), EventMeta{})))
+	fmt.Println(ValidateEvent(nil, NewEvent([]byte(`not json`), EventMeta{})))
+
+	//Output:
+	//<nil>
+	//[] <nil>
+	//[value: Invalid type. Expected: number, given: string] <nil>
+	//[(root): device_id is required] <nil>
+	//[invalid json: unexpected end of JSON input] <nil>
+	//[] <nil>
+}
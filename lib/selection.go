@@ -20,6 +20,7 @@ import (
 	"net/url"
 
 	"errors"
+	"fmt"
 
 	"github.com/olivere/elastic"
 	"github.com/SmartEnergyPlatform/jwt-http-router"
@@ -60,6 +61,7 @@ func UseSelection(query *elastic.BoolQuery, target string, endpoint string, jwt
 		err = errors.New("unknown target endpoint: " + target + " " + endpoint)
 		return
 	}
+	filterTombstones(query, target)
 	if !config.Selection.All {
 		filter, err := config.Selection.GetFilter(jwt, params)
 		if err != nil {
@@ -135,3 +137,111 @@ func (this ConditionConfig) GetFilter(jwt jwt_http_router.Jwt, values url.Values
 	}
 	return nil, errors.New("unknown query opperation type " + string(this.Operation))
 }
+
+// in memory equivalent of GetFilter; used to filter documents of subscriptions
+func (this SelectionConfig) Match(jwt jwt_http_router.Jwt, values url.Values, doc map[string]interface{}) (bool, error) {
+	if this.All {
+		return true, nil
+	}
+	if len(this.And) > 0 {
+		for _, sub := range this.And {
+			match, err := sub.Match(jwt, values, doc)
+			if err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if len(this.Or) > 0 {
+		for _, sub := range this.Or {
+			match, err := sub.Match(jwt, values, doc)
+			if err != nil || match {
+				return match, err
+			}
+		}
+		return false, nil
+	}
+	return this.Condition.Match(jwt, values, doc)
+}
+
+func (this ConditionConfig) Match(jwt jwt_http_router.Jwt, values url.Values, doc map[string]interface{}) (bool, error) {
+	val := this.Value
+	if val == nil || val == "" {
+		switch this.Ref {
+		case "jwt.user":
+			val = jwt.UserId
+		case "jwt.groups":
+			val = jwt.RealmAccess.Roles
+		default:
+			val = values.Get(this.Ref)
+		}
+	}
+	docValues := getDocumentValues(doc, this.Feature)
+	switch this.Operation {
+	case QueryEqualOperation:
+		if val == nil || val == "" {
+			return len(docValues) == 0, nil
+		}
+		return containsTerm(docValues, val), nil
+	case QueryUnequalOperation:
+		if val == nil || val == "" {
+			return len(docValues) > 0, nil
+		}
+		return !containsTerm(docValues, val), nil
+	case QueryAnyValueInFeatureOperation:
+		if reflect.TypeOf(val).Kind() == reflect.String {
+			val = strings.Split(val.(string), ",")
+		}
+		arr, err := InterfaceSlice(val)
+		if err != nil {
+			return false, err
+		}
+		for _, element := range arr {
+			if containsTerm(docValues, element) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, errors.New("unknown query opperation type " + string(this.Operation))
+}
+
+// values of the '.' separated field; lists are flattened like elasticsearch does while indexing
+func getDocumentValues(doc interface{}, field string) (result []interface{}) {
+	current := []interface{}{doc}
+	for _, key := range strings.Split(field, ".") {
+		next := []interface{}{}
+		for _, element := range current {
+			if object, ok := element.(map[string]interface{}); ok {
+				if value, ok := object[key]; ok && value != nil {
+					next = append(next, flattenDocumentValue(value)...)
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func flattenDocumentValue(value interface{}) (result []interface{}) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return []interface{}{value}
+	}
+	for _, element := range list {
+		if element != nil {
+			result = append(result, flattenDocumentValue(element)...)
+		}
+	}
+	return
+}
+
+// term queries on keyword fields compare the string representation
+func containsTerm(docValues []interface{}, term interface{}) bool {
+	for _, value := range docValues {
+		if reflect.DeepEqual(value, term) || fmt.Sprint(value) == fmt.Sprint(term) {
+			return true
+		}
+	}
+	return false
+}
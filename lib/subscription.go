@@ -0,0 +1,216 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+const subscriptionBufferSize = 100
+const subscriptionKeepAlive = 30 * time.Second
+
+// pushed to subscribers if a document starts matching (create), changes (update) or stops matching (delete) the subscription
+type SubscriptionEvent struct {
+	Operation TargetOperation        `json:"operation"`
+	Target    string                 `json:"target"`
+	Id        string                 `json:"id"`
+	Version   *int64                 `json:"version"`
+	Document  map[string]interface{} `json:"document,omitempty"`
+}
+
+type Subscription struct {
+	Target   string
+	Endpoint string
+	Jwt      jwt_http_router.Jwt
+	Params   url.Values
+	Field    string //optional filter; Value is compared with the document field like in /select/field
+	Value    string
+	Events   chan SubscriptionEvent
+}
+
+type SubscriptionHub struct {
+	mux           sync.RWMutex
+	subscriptions map[string]map[*Subscription]bool
+}
+
+var subscriptions = &SubscriptionHub{subscriptions: map[string]map[*Subscription]bool{}}
+
+func (this *SubscriptionHub) Subscribe(subscription *Subscription) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.subscriptions[subscription.Target] == nil {
+		this.subscriptions[subscription.Target] = map[*Subscription]bool{}
+	}
+	this.subscriptions[subscription.Target][subscription] = true
+}
+
+func (this *SubscriptionHub) Unsubscribe(subscription *Subscription) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.unsubscribe(subscription)
+}
+
+func (this *SubscriptionHub) unsubscribe(subscription *Subscription) {
+	if this.subscriptions[subscription.Target][subscription] {
+		delete(this.subscriptions[subscription.Target], subscription)
+		close(subscription.Events)
+	}
+}
+
+func (this *SubscriptionHub) HasSubscriptions(target string) bool {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	return len(this.subscriptions[target]) > 0
+}
+
+// previous are the features of the target before the actions; nil for new targets
+// events are computed without holding the lock; subscribers that are unable to keep up are unsubscribed
+func (this *SubscriptionHub) Notify(target Target, previous map[string]interface{}) {
+	if target.New && target.Removed {
+		return
+	}
+	this.mux.RLock()
+	candidates := make([]*Subscription, 0, len(this.subscriptions[target.Name]))
+	for subscription := range this.subscriptions[target.Name] {
+		candidates = append(candidates, subscription)
+	}
+	this.mux.RUnlock()
+
+	events := map[*Subscription]SubscriptionEvent{}
+	for _, subscription := range candidates {
+		if event, ok := subscription.getEvent(target, previous); ok {
+			events[subscription] = event
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for subscription, event := range events {
+		if !this.subscriptions[subscription.Target][subscription] {
+			continue //unsubscribed while computing the events
+		}
+		select {
+		case subscription.Events <- event:
+		default:
+			log.Println("WARNING: subscription buffer full; unsubscribe", subscription.Target, subscription.Endpoint)
+			this.unsubscribe(subscription)
+		}
+	}
+}
+
+func (this *Subscription) getEvent(target Target, previous map[string]interface{}) (result SubscriptionEvent, ok bool) {
+	endpoint, ok := Config.Queries[this.Target][this.Endpoint]
+	if !ok {
+		return result, false
+	}
+	matchedBefore := false
+	if target.Removed {
+		matchedBefore = this.match(endpoint, target.Features)
+	} else if previous != nil && !target.New {
+		matchedBefore = this.match(endpoint, previous)
+	}
+	matchesNow := !target.Removed && this.match(endpoint, target.Features)
+	result = SubscriptionEvent{Target: target.Name, Id: target.Id, Version: target.Version}
+	switch {
+	case matchesNow && matchedBefore:
+		result.Operation = UpdateOperation
+	case matchesNow:
+		result.Operation = CreateOperation
+	case matchedBefore:
+		result.Operation = DeleteOperation
+	default:
+		return result, false
+	}
+	if matchesNow {
+		result.Document = endpoint.Projection.Use(target.Features)
+	}
+	return result, true
+}
+
+func (this *Subscription) match(endpoint QueryEndpoint, doc map[string]interface{}) bool {
+	if this.Field != "" && !containsTerm(getDocumentValues(doc, this.Field), this.Value) {
+		return false
+	}
+	match, err := endpoint.Selection.Match(this.Jwt, this.Params, doc)
+	if err != nil {
+		log.Println("ERROR: while matching subscription", this.Target, this.Endpoint, err)
+		return false
+	}
+	return match
+}
+
+// copy of the target features if the target has subscribers
+func getSubscriptionSnapshot(target Target) map[string]interface{} {
+	if target.New || !subscriptions.HasSubscriptions(target.Name) {
+		return nil
+	}
+	return copyJsonValue(target.Features).(map[string]interface{})
+}
+
+// streams subscription events as server-sent events until the client disconnects
+func ServeSubscription(res http.ResponseWriter, r *http.Request, subscription *Subscription) error {
+	if _, ok := Config.Queries[subscription.Target][subscription.Endpoint]; !ok {
+		return errors.New("unknown target endpoint: " + subscription.Target + " " + subscription.Endpoint)
+	}
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		return errors.New("streaming not supported")
+	}
+	subscription.Events = make(chan SubscriptionEvent, subscriptionBufferSize)
+	subscriptions.Subscribe(subscription)
+	defer subscriptions.Unsubscribe(subscription)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(subscriptionKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-keepAlive.C:
+			fmt.Fprint(res, ": keep-alive\n\n")
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return nil
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				log.Println("ERROR: subscription event marshaling:", err)
+				continue
+			}
+			fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Operation, payload)
+		}
+		flusher.Flush()
+	}
+}
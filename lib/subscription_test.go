@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+func ExampleSubscriptionHub_Notify() {
+	config := ConfigStruct{}
+	json.Unmarshal([]byte(`{"queries": {"device": {"own": {
+		"selection": {"condition": {"feature": "owner", "operation": "==", "ref": "jwt.user"}},
+		"projection": ["name"]
+	}}}}`), &config)
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	Config = &config
+
+	hub := &SubscriptionHub{subscriptions: map[string]map[*Subscription]bool{}}
+	all := &Subscription{Target: "device", Endpoint: "own", Jwt: jwt_http_router.Jwt{UserId: "u1"}, Params: url.Values{}, Events: make(chan SubscriptionEvent, 10)}
+	filtered := &Subscription{Target: "device", Endpoint: "own", Jwt: jwt_http_router.Jwt{UserId: "u1"}, Params: url.Values{}, Field: "tags", Value: "a", Events: make(chan SubscriptionEvent, 10)}
+	hub.Subscribe(all)
+	hub.Subscribe(filtered)
+
+	version := int64(1)
+	hub.Notify(Target{Name: "device", Id: "1", Version: &version, New: true, Features: map[string]interface{}{"name": "foo", "owner": "u1", "tags": []interface{}{"a", "b"}}}, nil)
+	hub.Notify(Target{Name: "device", Id: "2", Version: &version, New: true, Features: map[string]interface{}{"name": "bar", "owner": "u2"}}, nil)
+	hub.Notify(Target{Name: "device", Id: "1", Version: &version, Features: map[string]interface{}{"name": "foo2", "owner": "u1"}}, map[string]interface{}{"name": "foo", "owner": "u1", "tags": []interface{}{"a", "b"}})
+	hub.Notify(Target{Name: "device", Id: "1", Version: &version, Features: map[string]interface{}{"name": "foo2", "owner": "u2"}}, map[string]interface{}{"name": "foo2", "owner": "u1"})
+	hub.Notify(Target{Name: "device", Id: "2", Version: &version, Removed: true, Features: map[string]interface{}{"name": "bar", "owner": "u1"}}, nil)
+	hub.Notify(Target{Name: "other", Id: "3", Version: &version, New: true, Features: map[string]interface{}{"name": "baz", "owner": "u1"}}, nil)
+	hub.Notify(Target{Name: "device", Id: "4", Version: &version, New: true, Removed: true, Features: map[string]interface{}{"name": "tmp", "owner": "u1"}}, nil)
+
+	hub.Unsubscribe(all)
+	hub.Unsubscribe(filtered)
+	for event := range all.Events {
+		out, _ := json.Marshal(event)
+		fmt.Println("all", string(out))
+	}
+	for event := range filtered.Events {
+		out, _ := json.Marshal(event)
+		fmt.Println("filtered", string(out))
+	}
+
+	//Output:
+	//all {"operation":"create","target":"device","id":"1","version":1,"document":{"name":"foo"}}
+	//all {"operation":"update","target":"device","id":"1","version":1,"document":{"name":"foo2"}}
+	//all {"operation":"delete","target":"device","id":"1","version":1}
+	//all {"operation":"delete","target":"device","id":"2","version":1}
+	//filtered {"operation":"create","target":"device","id":"1","version":1,"document":{"name":"foo"}}
+	//filtered {"operation":"delete","target":"device","id":"1","version":1}
+}
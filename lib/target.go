@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 
 	"errors"
+	"io"
 	"log"
 
 	"github.com/olivere/elastic"
@@ -35,6 +36,16 @@ type Target struct {
 	Removed  bool
 	New      bool
 	Name     string
+	Changes  []TargetChange
+}
+
+// describes which action changed a target; topic and group are set by the group handler
+type TargetChange struct {
+	Topic      string     `json:"topic"`
+	Group      string     `json:"group"`
+	GroupIndex int        `json:"group_index"` //position of the action-group in the events of the topic
+	Action     int        `json:"action"`
+	Type       ActionType `json:"type"`
 }
 
 type Sorting struct {
@@ -49,7 +60,7 @@ func GetTargetsWhere(targetName string, where WhereConditions, features map[stri
 	if err != nil {
 		return result, err
 	}
-	query := elastic.NewBoolQuery().Filter(filter...)
+	query := filterTombstones(elastic.NewBoolQuery().Filter(filter...), targetName)
 	resp, err := GetClient().Search().Index(targetName).Type(ElasticResourceType).Version(true).Query(query).Do(ctx)
 	if err != nil {
 		return result, err
@@ -66,6 +77,36 @@ func GetTargetsWhere(targetName string, where WhereConditions, features map[stri
 	return result, err
 }
 
+// scrolls through all matching documents instead of returning the first 10
+func GetAllTargetsWhere(targetName string, where WhereConditions, features map[string]interface{}) (result []Target, err error) {
+	ctx := context.Background()
+	filter, err := where.ToElasticFilter(features)
+	if err != nil {
+		return result, err
+	}
+	query := filterTombstones(elastic.NewBoolQuery().Filter(filter...), targetName)
+	scroll := GetClient().Scroll(targetName).Type(ElasticResourceType).Version(true).Query(query).Size(100)
+	defer scroll.Clear(ctx)
+	for {
+		resp, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		for _, hit := range resp.Hits.Hits {
+			fields := map[string]interface{}{}
+			err = json.Unmarshal(*hit.Source, &fields)
+			if err != nil {
+				return result, err
+			}
+			target := Target{Id: hit.Id, Version: hit.Version, Features: fields, Name: targetName}
+			result = append(result, target)
+		}
+	}
+}
+
 func GetTargetsWhereSorted(targetName string, where WhereConditions, features map[string]interface{}, sorting Sorting) (result []Target, err error) {
 	if sorting.By == "" {
 		return GetTargetsWhere(targetName, where, features)
@@ -78,7 +119,7 @@ func GetTargetsWhereSorted(targetName string, where WhereConditions, features ma
 	if err != nil {
 		return result, err
 	}
-	query := elastic.NewBoolQuery().Filter(filter...)
+	query := filterTombstones(elastic.NewBoolQuery().Filter(filter...), targetName)
 	resp, err := GetClient().Search().Index(targetName).Type(ElasticResourceType).Version(true).Query(query).Size(sorting.Limit).Sort(sorting.By, sorting.Asc).Do(ctx)
 	if err != nil {
 		return result, err
@@ -140,22 +181,50 @@ func GetTargetById(targetName string, idFeature string, features map[string]inte
 	return result, true, err
 }
 
-func (target Target) Save() (err error) {
+// completes changes recorded by Actions.Do
+func (target *Target) setChangeSource(topic string, group string, groupIndex int) {
+	for index, change := range target.Changes {
+		if change.Topic == "" && change.Group == "" {
+			target.Changes[index].Topic = topic
+			target.Changes[index].Group = group
+			target.Changes[index].GroupIndex = groupIndex
+		}
+	}
+}
+
+// updates the version of the target on success
+func (target *Target) Save() (err error) {
 	ctx := context.Background()
+	var resp *elastic.IndexResponse
 	if target.New {
-		_, err = GetClient().Index().Index(target.Name).Type(ElasticResourceType).Id(target.Id).OpType("create").BodyJson(target.Features).Do(ctx)
+		resp, err = GetClient().Index().Index(target.Name).Type(ElasticResourceType).Id(target.Id).OpType("create").BodyJson(target.Features).Do(ctx)
 	} else {
-		_, err = GetClient().Index().Index(target.Name).Type(ElasticResourceType).Id(target.Id).Version(*target.Version).BodyJson(target.Features).Do(ctx)
+		resp, err = GetClient().Index().Index(target.Name).Type(ElasticResourceType).Id(target.Id).Version(*target.Version).BodyJson(target.Features).Do(ctx)
 	}
 	if err != nil {
 		log.Println("ERROR: save: ", err, target)
+		return
 	}
+	target.Version = &resp.Version
 	return
 }
 
-func (target Target) Remove() (err error) {
-	if !target.New {
-		_, err = elastic.NewDeleteService(GetClient()).Index(target.Name).Type(ElasticResourceType).Id(target.Id).Version(*target.Version).Do(context.Background())
+// updates the version of the target on success
+// targets with soft delete are replaced by a tombstone
+func (target *Target) Remove() (err error) {
+	if !target.New && useTombstones(target.Name) {
+		resp, err := GetClient().Index().Index(target.Name).Type(ElasticResourceType).Id(target.Id).Version(*target.Version).BodyJson(newTombstoneFeatures(*target)).Do(context.Background())
+		if err != nil {
+			log.Println("ERROR: tombstone: ", err, target)
+			return err
+		}
+		target.Version = &resp.Version
+	} else if !target.New {
+		resp, err := elastic.NewDeleteService(GetClient()).Index(target.Name).Type(ElasticResourceType).Id(target.Id).Version(*target.Version).Do(context.Background())
+		if err != nil {
+			return err
+		}
+		target.Version = &resp.Version
 	}
 	return
 }
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/olivere/elastic"
+)
+
+// removed documents of targets with soft delete are replaced by a document containing only this field
+const TombstoneField = "matview_tombstone"
+
+const maxTombstoneCleanupInterval = time.Hour
+
+type TombstoneConfig struct {
+	Ttl string `json:"ttl"` //duration (for example "72h") after which tombstones are deleted; empty to keep tombstones
+}
+
+type Tombstone struct {
+	DeletedAt int64 `json:"deleted_at"` //unix time in ms
+	Version   int64 `json:"version"`    //elasticsearch version of the document before the deletion
+}
+
+func useTombstones(targetName string) bool {
+	if Config == nil {
+		return false
+	}
+	_, ok := Config.Tombstones[targetName]
+	return ok
+}
+
+func GetTombstone(features map[string]interface{}) (result Tombstone, ok bool) {
+	value, ok := features[TombstoneField].(map[string]interface{})
+	if !ok {
+		return result, false
+	}
+	if deletedAt, isNumber := toNumber(value["deleted_at"]).(float64); isNumber {
+		result.DeletedAt = int64(deletedAt)
+	}
+	if version, isNumber := toNumber(value["version"]).(float64); isNumber {
+		result.Version = int64(version)
+	}
+	return result, true
+}
+
+// version fields of the removed target are kept to skip stale events
+func newTombstoneFeatures(target Target) map[string]interface{} {
+	tombstone := Target{Features: map[string]interface{}{TombstoneField: map[string]interface{}{
+		"deleted_at": time.Now().UnixNano() / int64(time.Millisecond),
+		"version":    *target.Version,
+	}}}
+	for _, field := range getVersionFields(target.Name) {
+		if value, ok := target.getFieldPath(field); ok {
+			tombstone.setFieldPath(field, value)
+		}
+	}
+	return tombstone.Features
+}
+
+// hides tombstones of targets with soft delete
+func filterTombstones(query *elastic.BoolQuery, targetName string) *elastic.BoolQuery {
+	if useTombstones(targetName) {
+		query.MustNot(elastic.NewExistsQuery(TombstoneField))
+	}
+	return query
+}
+
+// periodically deletes tombstones older than the configured ttl
+func StartTombstoneCleanup() {
+	for targetName, config := range Config.Tombstones {
+		if config.Ttl == "" {
+			continue
+		}
+		ttl, err := time.ParseDuration(config.Ttl)
+		if err != nil {
+			log.Println("ERROR: invalid tombstone ttl", targetName, config.Ttl, err)
+			continue
+		}
+		go runTombstoneCleanup(targetName, ttl)
+	}
+}
+
+// without ttl a tombstone is kept forever; only events with a newer version_feature may recreate the document
+func validateTombstones(config ConfigStruct) error {
+	for targetName, tombstoneConfig := range config.Tombstones {
+		if tombstoneConfig.Ttl != "" {
+			ttl, err := time.ParseDuration(tombstoneConfig.Ttl)
+			if err == nil && ttl <= 0 {
+				err = errors.New("ttl has to be positive")
+			}
+			if err != nil {
+				return errors.New("invalid tombstone ttl of " + targetName + ": " + err.Error())
+			}
+			continue
+		}
+		for topic, groups := range config.Events {
+			for _, group := range groups {
+				if group.Type == RootGroupType && group.Target == targetName && group.VersionFeature == "" {
+					return errors.New("tombstones of " + targetName + " without ttl need a version_feature in the root group of topic " + topic)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func runTombstoneCleanup(targetName string, ttl time.Duration) {
+	interval := ttl
+	if interval > maxTombstoneCleanupInterval {
+		interval = maxTombstoneCleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := CleanupTombstones(targetName, ttl)
+		if err != nil {
+			log.Println("ERROR: tombstone cleanup", targetName, err)
+		}
+	}
+}
+
+func CleanupTombstones(targetName string, ttl time.Duration) error {
+	deadline := time.Now().Add(-ttl).UnixNano() / int64(time.Millisecond)
+	query := elastic.NewRangeQuery(TombstoneField + ".deleted_at").Lt(deadline)
+	_, err := GetClient().DeleteByQuery(targetName).Type(ElasticResourceType).Query(query).ProceedOnVersionConflict().Do(context.Background())
+	return err
+}
+
+func getTombstoneMapping() map[string]interface{} {
+	return map[string]interface{}{"properties": map[string]interface{}{
+		"deleted_at": map[string]string{"type": "long"},
+		"version":    map[string]string{"type": "long"},
+	}}
+}
+
+// indices created before the target used tombstones need the tombstone field to filter and clean up
+func putTombstoneMapping(kind string, client *elastic.Client, ctx context.Context) error {
+	_, err := client.PutMapping().Index(kind).Type(ElasticResourceType).BodyJson(map[string]interface{}{
+		"properties": map[string]interface{}{TombstoneField: getTombstoneMapping()},
+	}).Do(ctx)
+	return err
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/olivere/elastic"
+)
+
+func ExampleGetTombstone() {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	Config = &ConfigStruct{Tombstones: map[string]TombstoneConfig{"device": {Ttl: "24h"}}}
+
+	features := map[string]interface{}{}
+	json.Unmarshal([]byte(`{"matview_tombstone": {"deleted_at": 1543308512000, "version": 3}}`), &features)
+	fmt.Println(GetTombstone(features))
+	fmt.Println(GetTombstone(map[string]interface{}{"name": "foo"}))
+
+	version := int64(5)
+	tombstone, ok := GetTombstone(newTombstoneFeatures(Target{Version: &version}))
+	fmt.Println(tombstone.Version, tombstone.DeletedAt > 0, ok)
+
+	for _, target := range []string{"device", "other"} {
+		source, _ := filterTombstones(elastic.NewBoolQuery(), target).Source()
+		out, _ := json.Marshal(source)
+		fmt.Println(target, string(out))
+	}
+
+	groups := EventsConfig{"deviceinstance": {{Type: RootGroupType, Target: "device"}}}
+	fmt.Println(validateTombstones(ConfigStruct{Events: groups, Tombstones: map[string]TombstoneConfig{"device": {Ttl: "72h"}}}))
+	fmt.Println(validateTombstones(ConfigStruct{Events: groups, Tombstones: map[string]TombstoneConfig{"device": {}}}))
+	groups["deviceinstance"][0].VersionFeature = "time"
+	fmt.Println(validateTombstones(ConfigStruct{Events: groups, Tombstones: map[string]TombstoneConfig{"device": {}}}))
+
+	//Output:
+	//{1543308512000 3} true
+	//{0 0} false
+	//5 true true
+	//device {"bool":{"must_not":{"exists":{"field":"matview_tombstone"}}}}
+	//other {"bool":{}}
+	//<nil>
+	//tombstones of device without ttl need a version_feature in the root group of topic deviceinstance
+	//<nil>
+}
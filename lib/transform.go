@@ -0,0 +1,253 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FeatureTransformType string
+
+const (
+	ToNumberTransform  FeatureTransformType = "to_number"
+	ToStringTransform  FeatureTransformType = "to_string"
+	LowercaseTransform FeatureTransformType = "lowercase"
+	TrimTransform      FeatureTransformType = "trim"
+	ConcatTransform    FeatureTransformType = "concat"
+	SplitTransform     FeatureTransformType = "split"
+	JoinTransform      FeatureTransformType = "join"
+	ParseTimeTransform FeatureTransformType = "parse_time"
+	FlattenTransform   FeatureTransformType = "flatten"
+	LookupTransform    FeatureTransformType = "lookup"
+)
+
+type FeatureTransform struct {
+	Type       FeatureTransformType `json:"type"`
+	Separator  string               `json:"separator"`
+	Features   []string             `json:"features"`
+	Format     string               `json:"format"`
+	Key        string               `json:"key"`
+	KeyFeature string               `json:"key_feature"`
+}
+
+type FeatureTransforms []FeatureTransform
+
+// unknown transform types are reported on config load by CompileFeatures
+func (this FeatureTransform) Validate() error {
+	switch this.Type {
+	case ToNumberTransform, ToStringTransform, LowercaseTransform, TrimTransform, ConcatTransform, SplitTransform, JoinTransform, ParseTimeTransform, FlattenTransform, LookupTransform:
+		return nil
+	}
+	return errors.New("unknown feature transform type " + string(this.Type))
+}
+
+// values that can not be transformed result in nil, so that default and omitempty of the feature can handle them
+func (this FeatureTransforms) Use(value interface{}, features Features) (result interface{}, err error) {
+	result = value
+	for _, transform := range this {
+		result, err = transform.Use(result, features)
+		if err != nil {
+			return result, err
+		}
+	}
+	return
+}
+
+func (this FeatureTransform) Use(value interface{}, features Features) (interface{}, error) {
+	switch this.Type {
+	case ToNumberTransform:
+		return useOnElements(value, toNumber), nil
+	case ToStringTransform:
+		return useOnElements(value, toString), nil
+	case LowercaseTransform:
+		return useOnElements(value, func(value interface{}) interface{} {
+			if str, ok := value.(string); ok {
+				return strings.ToLower(str)
+			}
+			return nil
+		}), nil
+	case TrimTransform:
+		return useOnElements(value, func(value interface{}) interface{} {
+			if str, ok := value.(string); ok {
+				return strings.TrimSpace(str)
+			}
+			return nil
+		}), nil
+	case ConcatTransform:
+		parts := []string{}
+		if value != nil {
+			parts = append(parts, joinableString(value))
+		}
+		for _, name := range this.Features {
+			if val, ok := features.Get(name); ok && val != nil {
+				parts = append(parts, joinableString(val))
+			}
+		}
+		if len(parts) == 0 {
+			return nil, nil
+		}
+		return strings.Join(parts, this.Separator), nil
+	case SplitTransform:
+		str, ok := value.(string)
+		if !ok {
+			return nil, nil
+		}
+		result := []interface{}{}
+		for _, part := range strings.Split(str, this.Separator) {
+			result = append(result, part)
+		}
+		return result, nil
+	case JoinTransform:
+		list, err := InterfaceSlice(value)
+		if err != nil {
+			return nil, nil
+		}
+		parts := []string{}
+		for _, element := range list {
+			parts = append(parts, joinableString(element))
+		}
+		return strings.Join(parts, this.Separator), nil
+	case ParseTimeTransform:
+		format := this.Format
+		if format == "" {
+			format = time.RFC3339
+		}
+		return useOnElements(value, func(value interface{}) interface{} {
+			str, ok := value.(string)
+			if !ok {
+				return nil
+			}
+			t, err := time.Parse(format, str)
+			if err != nil {
+				return nil
+			}
+			return t.UnixNano() / int64(time.Millisecond)
+		}), nil
+	case FlattenTransform:
+		if value == nil || reflect.TypeOf(value).Kind() != reflect.Slice {
+			return value, nil
+		}
+		return flatten(value), nil
+	case LookupTransform:
+		key := this.Key
+		if this.KeyFeature != "" {
+			val, ok := features.Get(this.KeyFeature)
+			if !ok || val == nil {
+				return nil, nil
+			}
+			key = joinableString(val)
+		}
+		if value == nil || reflect.TypeOf(value).Kind() != reflect.Map || reflect.TypeOf(value).Key().Kind() != reflect.String {
+			return nil, nil
+		}
+		element := reflect.ValueOf(value).MapIndex(reflect.ValueOf(key).Convert(reflect.TypeOf(value).Key()))
+		if !element.IsValid() {
+			return nil, nil
+		}
+		return element.Interface(), nil
+	}
+	return value, this.Validate()
+}
+
+// applies f on value or, if value is a list, on each element of the list
+func useOnElements(value interface{}, f func(interface{}) interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		result := []interface{}{}
+		for _, element := range list {
+			result = append(result, f(element))
+		}
+		return result
+	}
+	return f(value)
+}
+
+func toNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil
+		}
+		return f
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	}
+	return nil
+}
+
+func toString(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value)
+	}
+	return nil
+}
+
+// primitives are formatted like toString, other values as json
+func joinableString(value interface{}) string {
+	if str, ok := toString(value).(string); ok {
+		return str
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func flatten(value interface{}) (result []interface{}) {
+	result = []interface{}{}
+	if value == nil || reflect.TypeOf(value).Kind() != reflect.Slice {
+		return append(result, value)
+	}
+	list, _ := InterfaceSlice(value)
+	for _, element := range list {
+		if element != nil && reflect.TypeOf(element).Kind() == reflect.Slice {
+			result = append(result, flatten(element)...)
+		} else {
+			result = append(result, element)
+		}
+	}
+	return
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func ExampleFeatureTransforms_Use() {
+	msg := `{
+		"count": "42",
+		"size": 1.5,
+		"name": "  Foo Bar ",
+		"device": "d1",
+		"service": "s1",
+		"tags": "a,b,c",
+		"list": ["x", "y"],
+		"date": "2018-10-18T12:00:00Z",
+		"date2": "18.10.2018",
+		"nested": [[1, 2], [3, [4]]],
+		"services": {"d1": ["s1", "s2"], "d2": ["s3"]},
+		"invalid": "abc"
+	}`
+	featuresStr := `[
+		{"name": "count", "path": "$.count+", "transforms": [{"type": "to_number"}]},
+		{"name": "size", "path": "$.size+", "transforms": [{"type": "to_string"}]},
+		{"name": "name", "path": "$.name+", "transforms": [{"type": "trim"}, {"type": "lowercase"}]},
+		{"name": "device", "path": "$.device+", "temp": true},
+		{"name": "id", "path": "$.service+", "transforms": [{"type": "concat", "features": ["device"], "separator": ":"}]},
+		{"name": "composite", "transforms": [{"type": "concat", "features": ["device", "count"], "separator": "_"}]},
+		{"name": "tags", "path": "$.tags+", "transforms": [{"type": "split", "separator": ","}]},
+		{"name": "list", "path": "$.list+", "transforms": [{"type": "join", "separator": "|"}]},
+		{"name": "date", "path": "$.date+", "transforms": [{"type": "parse_time"}]},
+		{"name": "date2", "path": "$.date2+", "transforms": [{"type": "parse_time", "format": "02.01.2006"}]},
+		{"name": "nested", "path": "$.nested+", "transforms": [{"type": "flatten"}]},
+		{"name": "services", "path": "$.services+", "transforms": [{"type": "lookup", "key_feature": "device"}]},
+		{"name": "services2", "path": "$.services+", "transforms": [{"type": "lookup", "key": "d2"}]},
+		{"name": "invalid", "path": "$.invalid+", "default": -1, "transforms": [{"type": "to_number"}]}
+	]`
+	features := []Feature{}
+	err := json.Unmarshal([]byte(featuresStr), &features)
+	fmt.Println(err)
+
+	_, perm, err := MsgToFeatures(features, []byte(msg))
+	fmt.Println(err)
+	for _, feature := range features {
+		if val, ok := perm[feature.Name]; ok {
+			fmt.Printf("%s: %#v\n", feature.Name, val)
+		}
+	}
+
+	_, _, err = MsgToFeatures([]Feature{{Name: "foo", Path: "$.name+", Transforms: FeatureTransforms{{Type: "foo"}}}}, []byte(msg))
+	fmt.Println(err)
+	fmt.Println(CompileFeatures([]Feature{{Name: "foo", Path: "$.name+", Transforms: FeatureTransforms{{Type: "foo"}}}}))
+
+	//output:
+	//<nil>
+	//<nil>
+	//count: 42
+	//size: "1.5"
+	//name: "foo bar"
+	//id: "s1:d1"
+	//composite: "d1_42"
+	//tags: []interface {}{"a", "b", "c"}
+	//list: "x|y"
+	//date: 1539864000000
+	//date2: 1539820800000
+	//nested: []interface {}{1, 2, 3, 4}
+	//services: []interface {}{"s1", "s2"}
+	//services2: []interface {}{"s3"}
+	//invalid: -1
+	//unknown feature transform type foo
+	//feature foo: unknown feature transform type foo
+}
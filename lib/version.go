@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"errors"
+	"log"
+)
+
+// groups of the same target may use different versions (for example timestamps of different topics),
+// so every group with version_feature names its own version_field
+func validateVersionFields(events EventsConfig) error {
+	for topic, groups := range events {
+		for _, group := range groups {
+			if group.VersionFeature != "" && group.VersionField == "" {
+				return errors.New("topic " + topic + ": version_feature " + group.VersionFeature + " of " + group.Target + " needs a version_field")
+			}
+		}
+	}
+	return nil
+}
+
+// returns the version of the event and false if the target already contains the same or a newer version
+// events without version feature are not checked
+func (this EventActionGroup) CheckVersion(target Target, temp Features) (eventVersion interface{}, ok bool) {
+	if this.VersionFeature == "" {
+		return nil, true
+	}
+	eventVersion, ok = temp.Get(this.VersionFeature)
+	if !ok || eventVersion == nil {
+		log.Println("WARNING: event without version feature", this.Target, this.VersionFeature)
+		return nil, true
+	}
+	targetVersion, ok := target.getFieldPath(this.VersionField)
+	if !ok || targetVersion == nil {
+		return eventVersion, true
+	}
+	newer, comparable := isNewerVersion(targetVersion, eventVersion)
+	if !comparable {
+		log.Println("WARNING: unable to compare versions", this.Target, targetVersion, eventVersion)
+		return eventVersion, true
+	}
+	return eventVersion, newer
+}
+
+// numbers are compared numerically, strings lexicographically; other combinations are not comparable
+func isNewerVersion(old interface{}, new interface{}) (newer bool, comparable bool) {
+	switch oldVersion := old.(type) {
+	case float64:
+		if newVersion, ok := new.(float64); ok {
+			return oldVersion < newVersion, true
+		}
+		if newVersion, ok := new.(int64); ok {
+			return oldVersion < float64(newVersion), true
+		}
+	case int64:
+		if newVersion, ok := new.(int64); ok {
+			return oldVersion < newVersion, true
+		}
+		if newVersion, ok := new.(float64); ok {
+			return float64(oldVersion) < newVersion, true
+		}
+	case string:
+		if newVersion, ok := new.(string); ok {
+			return oldVersion < newVersion, true
+		}
+	}
+	return false, false
+}
+
+// stores the event version in changed targets; removed targets keep the version in their tombstone
+func (this EventActionGroup) SetVersion(target *Target, eventVersion interface{}) error {
+	if eventVersion == nil || !target.Changed {
+		return nil
+	}
+	return target.setFieldPath(this.VersionField, eventVersion)
+}
+
+// version fields of all groups of the target; kept in tombstones to detect stale events
+func getVersionFields(targetName string) (result []string) {
+	if Config == nil {
+		return
+	}
+	known := map[string]bool{}
+	for _, groups := range Config.Events {
+		for _, group := range groups {
+			if group.Target == targetName && group.VersionFeature != "" && !known[group.VersionField] {
+				known[group.VersionField] = true
+				result = append(result, group.VersionField)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"fmt"
+)
+
+func ExampleEventActionGroup_CheckVersion() {
+	group := EventActionGroup{Target: "device", VersionFeature: "time", VersionField: "meta.time"}
+	target := Target{Name: "device", Id: "1", Features: map[string]interface{}{}}
+
+	for _, eventTime := range []interface{}{float64(10), float64(20), float64(15), float64(20), nil, float64(30)} {
+		temp := Features{}
+		if eventTime != nil {
+			temp["time"] = eventTime
+		}
+		eventVersion, ok := group.CheckVersion(target, temp)
+		fmt.Println(eventVersion, ok)
+		if ok {
+			target.Changed = true
+			group.SetVersion(&target, eventVersion)
+		}
+	}
+	fmt.Println(target.Features)
+
+	fmt.Println(EventActionGroup{Target: "device"}.CheckVersion(target, Features{"time": float64(1)}))
+	fmt.Println(validateVersionFields(EventsConfig{"device": {group}}))
+	fmt.Println(validateVersionFields(EventsConfig{"device": {{Target: "device", VersionFeature: "time"}}}))
+
+	version := int64(3)
+	target.Version = &version
+	target.Removed = true
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	Config = &ConfigStruct{Events: EventsConfig{"device": {group}}}
+	fmt.Println(newTombstoneFeatures(target)["meta"])
+
+	fmt.Println(isNewerVersion("2018-12-01T10:00:00Z", "2018-12-02T09:00:00Z"))
+	fmt.Println(isNewerVersion("10", "9"))
+	fmt.Println(isNewerVersion(float64(9), int64(10)))
+	fmt.Println(isNewerVersion(float64(9), "10"))
+
+	//Output:
+	//10 true
+	//20 true
+	//15 false
+	//20 false
+	//<nil> true
+	//30 true
+	//map[meta:map[time:30]]
+	//<nil> true
+	//<nil>
+	//topic device: version_feature time of device needs a version_field
+	//map[time:30]
+	//true true
+	//true true
+	//true true
+	//false false
+}
@@ -26,10 +26,19 @@ import (
 type WhereOperationType string
 
 const (
-	WhereEqualOperation   WhereOperationType = "=="
-	WhereUnequalOperation WhereOperationType = "!="
-	WhereAnyTargetInEvent WhereOperationType = "any_target_in_event"
-	WhereAnyTargetInValue WhereOperationType = "any_target_in_value"
+	WhereEqualOperation        WhereOperationType = "=="
+	WhereUnequalOperation      WhereOperationType = "!="
+	WhereAnyTargetInEvent      WhereOperationType = "any_target_in_event"
+	WhereAnyTargetInValue      WhereOperationType = "any_target_in_value"
+	WhereLessOperation         WhereOperationType = "<"
+	WhereLessEqualOperation    WhereOperationType = "<="
+	WhereGreaterOperation      WhereOperationType = ">"
+	WhereGreaterEqualOperation WhereOperationType = ">="
+	WherePrefixOperation       WhereOperationType = "prefix"
+	WhereExistsOperation       WhereOperationType = "exists"
+	WhereMissingOperation      WhereOperationType = "missing"
+	WhereMatchOperation        WhereOperationType = "match"
+	WhereNestedOperation       WhereOperationType = "nested"
 )
 
 type WhereCondition struct {
@@ -37,6 +46,9 @@ type WhereCondition struct {
 	Operation     WhereOperationType `json:"operation"`
 	EventFeature  string             `json:"event_feature"`
 	Value         interface{}        `json:"value"`
+	Where         WhereConditions    `json:"where"` //conditions on the elements of a nested target_feature
+	Or            []WhereConditions  `json:"or"`    //at least one of the condition lists must match
+	Not           WhereConditions    `json:"not"`   //the condition list must not match
 }
 
 type WhereConditions []WhereCondition
@@ -56,6 +68,39 @@ func (this WhereConditions) ToElasticFilter(features map[string]interface{}) (re
 }
 
 func (this WhereCondition) ToElasticFilter(features Features) (result elastic.Query, err error) {
+	if len(this.Or) == 0 && len(this.Not) == 0 {
+		return this.toOperationFilter(features)
+	}
+	query := elastic.NewBoolQuery()
+	if this.Operation != "" {
+		filter, err := this.toOperationFilter(features)
+		if err != nil {
+			return result, err
+		}
+		query.Filter(filter)
+	}
+	if len(this.Or) > 0 {
+		or := []elastic.Query{}
+		for _, sub := range this.Or {
+			filter, err := sub.ToElasticFilter(features)
+			if err != nil {
+				return result, err
+			}
+			or = append(or, elastic.NewBoolQuery().Filter(filter...))
+		}
+		query.Filter(elastic.NewBoolQuery().Should(or...))
+	}
+	if len(this.Not) > 0 {
+		filter, err := this.Not.ToElasticFilter(features)
+		if err != nil {
+			return result, err
+		}
+		query.MustNot(elastic.NewBoolQuery().Filter(filter...))
+	}
+	return query, err
+}
+
+func (this WhereCondition) toOperationFilter(features Features) (result elastic.Query, err error) {
 	switch this.Operation {
 	case WhereEqualOperation:
 		if this.EventFeature == "" {
@@ -120,7 +165,60 @@ func (this WhereCondition) ToElasticFilter(features Features) (result elastic.Qu
 			or = append(or, elastic.NewTermQuery(this.TargetFeature, value))
 		}
 		return elastic.NewBoolQuery().Filter(elastic.NewBoolQuery().Should(or...)), err
+	case WhereLessOperation, WhereLessEqualOperation, WhereGreaterOperation, WhereGreaterEqualOperation:
+		val, ok := this.getValue(features)
+		if !ok || val == nil {
+			return elastic.NewMatchNoneQuery(), err
+		}
+		query := elastic.NewRangeQuery(this.TargetFeature)
+		switch this.Operation {
+		case WhereLessOperation:
+			query = query.Lt(val)
+		case WhereLessEqualOperation:
+			query = query.Lte(val)
+		case WhereGreaterOperation:
+			query = query.Gt(val)
+		case WhereGreaterEqualOperation:
+			query = query.Gte(val)
+		}
+		return query, err
+	case WherePrefixOperation:
+		val, ok := this.getValue(features)
+		if !ok || val == nil {
+			return elastic.NewMatchNoneQuery(), err
+		}
+		prefix, ok := val.(string)
+		if !ok {
+			err = errors.New("prefix operation expects string value")
+			log.Println("ERROR: ", err, this, features)
+			return result, err
+		}
+		return elastic.NewPrefixQuery(this.TargetFeature, prefix), err
+	case WhereExistsOperation:
+		return elastic.NewExistsQuery(this.TargetFeature), err
+	case WhereMissingOperation:
+		return elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery(this.TargetFeature)), err
+	case WhereMatchOperation:
+		val, ok := this.getValue(features)
+		if !ok || val == nil {
+			return elastic.NewMatchNoneQuery(), err
+		}
+		return elastic.NewMatchQuery(this.TargetFeature, val), err
+	case WhereNestedOperation:
+		filter, err := this.Where.ToElasticFilter(features)
+		if err != nil {
+			return result, err
+		}
+		return elastic.NewNestedQuery(this.TargetFeature, elastic.NewBoolQuery().Filter(filter...)), err
 	}
 	err = errors.New("unknown WhereOperator " + string(this.Operation))
 	return
 }
+
+// uses the value field if set, else the referenced event feature
+func (this WhereCondition) getValue(features Features) (result interface{}, ok bool) {
+	if this.EventFeature == "" {
+		return this.Value, true
+	}
+	return features.Get(this.EventFeature)
+}
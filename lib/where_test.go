@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"encoding/json"
+	"fmt"
 
 	"reflect"
 
@@ -664,3 +665,74 @@ func TestWhere(t *testing.T) {
 	}
 
 }
+
+func ExampleWhereCondition_ToElasticFilter() {
+	features := Features{"date": 1543308512000, "name": "dev", "service_id": "s1"}
+	conditions := WhereConditions{}
+	err := json.Unmarshal([]byte(`[
+		{"target_feature": "date", "operation": "<", "event_feature": "date"},
+		{"target_feature": "date", "operation": ">=", "value": 1000},
+		{"target_feature": "date", "operation": "<=", "event_feature": "unknown"},
+		{"target_feature": "name", "operation": "prefix", "event_feature": "name"},
+		{"target_feature": "name", "operation": "exists"},
+		{"target_feature": "kind", "operation": "missing"},
+		{"target_feature": "description", "operation": "match", "value": "foo bar"},
+		{"target_feature": "services", "operation": "nested", "where": [
+			{"target_feature": "services.id", "operation": "==", "event_feature": "service_id"},
+			{"target_feature": "services.rights", "operation": "==", "value": "rwx"}
+		]}
+	]`), &conditions)
+	fmt.Println(err)
+	for _, condition := range conditions {
+		query, err := condition.ToElasticFilter(features)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		source, _ := query.Source()
+		out, _ := json.Marshal(source)
+		fmt.Println(string(out))
+	}
+	_, err = WhereCondition{TargetFeature: "name", Operation: WherePrefixOperation, Value: 42}.ToElasticFilter(features)
+	fmt.Println(err)
+
+	//Output:
+	//<nil>
+	//{"range":{"date":{"from":null,"include_lower":true,"include_upper":false,"to":1543308512000}}}
+	//{"range":{"date":{"from":1000,"include_lower":true,"include_upper":true,"to":null}}}
+	//{"match_none":{}}
+	//{"prefix":{"name":"dev"}}
+	//{"exists":{"field":"name"}}
+	//{"bool":{"must_not":{"exists":{"field":"kind"}}}}
+	//{"match":{"description":{"query":"foo bar"}}}
+	//{"nested":{"path":"services","query":{"bool":{"filter":[{"term":{"services.id":"s1"}},{"term":{"services.rights":"rwx"}}]}}}}
+	//prefix operation expects string value
+}
+
+func ExampleWhereConditions_ToElasticFilter() {
+	features := Features{"user": "u1", "group": "g1"}
+	conditions := WhereConditions{}
+	err := json.Unmarshal([]byte(`[
+		{"target_feature": "kind", "operation": "==", "value": "device"},
+		{"or": [
+			[{"target_feature": "owner", "operation": "==", "event_feature": "user"}],
+			[{"target_feature": "shared", "operation": "==", "event_feature": "group"}]
+		]},
+		{"not": [{"target_feature": "deleted", "operation": "==", "value": true}]}
+	]`), &conditions)
+	fmt.Println(err)
+	filter, err := conditions.ToElasticFilter(features)
+	fmt.Println(err)
+	for _, query := range filter {
+		source, _ := query.Source()
+		out, _ := json.Marshal(source)
+		fmt.Println(string(out))
+	}
+
+	//Output:
+	//<nil>
+	//<nil>
+	//{"term":{"kind":"device"}}
+	//{"bool":{"filter":{"bool":{"should":[{"bool":{"filter":{"term":{"owner":"u1"}}}},{"bool":{"filter":{"term":{"shared":"g1"}}}}]}}}}
+	//{"bool":{"must_not":{"bool":{"filter":{"term":{"deleted":true}}}}}}
+}
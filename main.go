@@ -41,6 +41,8 @@ func main() {
 		lib.GetClient()
 	} else {
 		lib.InitEventHandling()
+		lib.StartTombstoneCleanup()
+		lib.StartEventIdCleanup()
 		go lib.StartApi()
 
 		shutdown := make(chan os.Signal, 1)